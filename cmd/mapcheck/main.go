@@ -0,0 +1,51 @@
+// mapcheck loads every map the game would and prints everything wrong with them, it exits with 1
+// when there's anything worse than a warning so it can be run before committing map changes
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"rpg-sdl/game"
+)
+
+func main() {
+	content := flag.String("content", "", "directory of maps/ and data/ files on top of the built in ones, use game to check the maps being edited")
+	monsterFile := flag.String("monsters", game.DefaultMonsters, "file with the monster definitions, inside the content")
+	packs := flag.String("packs", "", "directory of content packs to check along with the rest")
+	flag.Parse()
+
+	if *content != "" {
+		info, err := os.Stat(*content)
+		if err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "content directory %s not found\n", *content)
+			os.Exit(1)
+		}
+		game.Content = game.Overlay(os.DirFS(*content), game.Content)
+	}
+
+	// monster runes on the maps are only known once the monsters are loaded
+	err := game.LoadMonsterTypes(*monsterFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *packs != "" {
+		err = game.LoadPacks(os.DirFS(*packs))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	for _, p := range game.CheckMaps(game.Content) {
+		fmt.Println(p)
+		if !p.Warning {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -9,6 +9,7 @@ type Tile struct {
 	BloodStained bool
 	HasFloor     bool
 	Cost         int
+	Light        int // how far the tile lights up around it, 0 for no light
 }
 
 const (
@@ -20,9 +21,13 @@ const (
 	Rat          rune = 'R'
 	Spider       rune = 'S'
 	Water        rune = '~'
+	Torch        rune = '*'
 	BloodStained rune = 'b'
 	UpStairs     rune = 'u'
 	DownStairs   rune = 'd'
+	Sword        rune = ')'
+	LeatherArmor rune = '['
+	HealthPotion rune = '!'
 	Empty        rune = 0
 )
 
@@ -77,6 +82,14 @@ func (l *Level) LoadTileMap() {
 			HasFloor: true,
 			Cost:     2,
 		},
+		{
+			Name:     "Torch",
+			Type:     "Wall",
+			Rune:     Torch,
+			HasFloor: false,
+			Cost:     0,
+			Light:    5,
+		},
 		{ // I don't like this
 			Type:     "Empty",
 			Rune:     Empty,
@@ -88,6 +101,9 @@ func (l *Level) LoadTileMap() {
 	for _, t := range tiles {
 		l.TileMap[t.Rune] = t
 	}
+	for r, t := range PackTiles {
+		l.TileMap[r] = t
+	}
 }
 
 func (l *Level) TileAtPos(pos Pos) *Tile {
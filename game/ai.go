@@ -0,0 +1,240 @@
+package game
+
+// Monster AI. Every monster type names a Behavior in monsters.txt, the behaviors that come with the
+// game are state machines: a monster is calm until it sees the player, then alert, and it can run
+// once it's badly hurt. Anything random comes from level.R so the same seed plays out the same way
+
+// Behavior decides what a monster does each time it gets to act
+type Behavior interface {
+	Act(m *Monster, level *Level)
+}
+
+type AIState int
+
+const (
+	Idle AIState = iota
+	Patrol
+	Wander
+	Hunt
+	Flee
+	KeepDistance
+)
+
+const (
+	monsterMemory = 10 // actions a monster keeps looking for the player after losing sight of it
+	patrolRange   = 8  // how far from its start a patrolling monster picks the other end of its route
+	keepAway      = 3  // ranged monsters back off when the player is closer than this
+	shootRange    = 6
+)
+
+// stateMachine is a Behavior made from states, calm is what the monster does while it doesn't know
+// where the player is and alert is what it does once it does
+type stateMachine struct {
+	calm   AIState
+	alert  AIState
+	fleeAt float64 // runs from the player below this part of its hitpoints, 0 never runs
+}
+
+var monsterBehaviors = map[string]Behavior{
+	"idle":   stateMachine{calm: Idle, alert: Idle},                         // never moves, only fights back
+	"wander": stateMachine{calm: Wander, alert: Wander, fleeAt: 0.5},        // minds its own business, runs when hurt
+	"hunt":   stateMachine{calm: Wander, alert: Hunt, fleeAt: 0.25},         // chases the player once it's been seen
+	"patrol": stateMachine{calm: Patrol, alert: Hunt, fleeAt: 0.25},         // walks a route until it sees the player
+	"coward": stateMachine{calm: Wander, alert: Flee},                       // runs as soon as it sees the player
+	"ranged": stateMachine{calm: Wander, alert: KeepDistance, fleeAt: 0.25}, // keeps its distance and shoots
+}
+
+// RegisterBehavior makes b usable by name in monster files, loading them checks the names
+func RegisterBehavior(name string, b Behavior) {
+	monsterBehaviors[name] = b
+}
+
+func (s stateMachine) Act(m *Monster, level *Level) {
+	m.State = s.next(m, level)
+
+	switch m.State {
+	case Idle:
+		m.fightBack(level)
+	case Patrol:
+		m.patrol(level)
+	case Wander:
+		m.wander(level)
+	case Hunt:
+		m.hunt(level)
+	case Flee:
+		m.flee(level)
+	case KeepDistance:
+		m.keepDistance(level)
+	}
+}
+
+// next remembers where the player was seen and picks the state for this action
+func (s stateMachine) next(m *Monster, level *Level) AIState {
+	p := level.Player
+	m.seesPlayer = p.Alive && m.isPlayerInRange(level)
+	if m.seesPlayer {
+		m.LastSeen = p.Pos
+		m.Memory = monsterMemory
+		m.alertPack(level)
+	} else if m.Memory > 0 {
+		m.Memory--
+	}
+	if !p.Alive {
+		m.Memory = 0
+	}
+
+	hurt := s.fleeAt > 0 && float64(m.Hitpoints) < s.fleeAt*float64(m.MaxHitpoints)
+	switch {
+	case m.Memory > 0 && hurt:
+		return Flee
+	case m.Memory > 0:
+		return s.alert
+	default:
+		return s.calm
+	}
+}
+
+// fightBack hits the player if it's right next to the monster
+func (m *Monster) fightBack(level *Level) {
+	p := level.Player
+	if p.Alive && chebyshev(m.Pos, p.Pos) == 1 && canStep(level, m.Pos, p.Pos) {
+		m.Move(p.Pos, level)
+	}
+}
+
+// wander takes a step in a random direction or stays put
+func (m *Monster) wander(level *Level) {
+	neighbours := getNeighbours(level, m.Pos, canWalk)
+	choice := level.R.Intn(len(neighbours) + 1)
+	if choice < len(neighbours) && neighbours[choice] != level.Player.Pos {
+		m.Move(neighbours[choice], level)
+	}
+}
+
+// stepTowards takes the first step of the way to pos, stepping onto the player attacks it
+func (m *Monster) stepTowards(level *Level, pos Pos) bool {
+	path, _, found := level.astar(m.Pos, pos)
+	if !found || len(path) < 2 {
+		return false
+	}
+	m.Move(path[1], level)
+	return true
+}
+
+// stepAway moves to the neighbour furthest from pos, if there's one further than where it is now
+func (m *Monster) stepAway(level *Level, pos Pos) bool {
+	best := m.Pos
+	bestDist := distSquared(m.Pos, pos)
+	for _, n := range getNeighbours(level, m.Pos, canWalk) {
+		if d := distSquared(n, pos); d > bestDist && n != level.Player.Pos {
+			best, bestDist = n, d
+		}
+	}
+	if best == m.Pos {
+		return false
+	}
+	m.Move(best, level)
+	return true
+}
+
+// patrol walks between two spots, the second one is picked the first time round
+func (m *Monster) patrol(level *Level) {
+	if m.Waypoint == m.Home {
+		m.Waypoint = m.pickWaypoint(level)
+	}
+	if m.Pos == m.Waypoint {
+		m.Home, m.Waypoint = m.Waypoint, m.Home
+	}
+	if !m.stepTowards(level, m.Waypoint) {
+		m.wander(level)
+	}
+}
+
+// pickWaypoint is a random floor tile near Home that can be walked to
+func (m *Monster) pickWaypoint(level *Level) Pos {
+	var candidates []Pos
+	for y := m.Home.Y - patrolRange; y <= m.Home.Y+patrolRange; y++ {
+		for x := m.Home.X - patrolRange; x <= m.Home.X+patrolRange; x++ {
+			pos := Pos{x, y}
+			if pos != m.Home && inRange(level, pos) && level.TileAtPos(pos).Type == "Floor" {
+				candidates = append(candidates, pos)
+			}
+		}
+	}
+	for len(candidates) > 0 {
+		i := level.R.Intn(len(candidates))
+		if _, _, found := level.findPath(m.Home, candidates[i], canPatrol); found {
+			return candidates[i]
+		}
+		candidates = append(candidates[:i], candidates[i+1:]...)
+	}
+	return m.Home
+}
+
+// canPatrol is canWalk without the monsters, they move around and shouldn't spoil a route
+func canPatrol(level *Level, pos Pos) bool {
+	switch level.TileAtPos(pos).Type {
+	case "Wall", "ClosedDoor", "Empty":
+		return false
+	}
+	return true
+}
+
+// hunt chases the player, or goes to where it was last seen and gives up if it isn't there. It goes
+// by the map shared with everyone else hunting the same spot and only plans its own path off the map
+func (m *Monster) hunt(level *Level) {
+	if m.Pos == m.LastSeen {
+		m.Memory = 0
+		m.wander(level)
+		return
+	}
+	if !m.huntStep(level, m.LastSeen) && !m.stepTowards(level, m.LastSeen) {
+		m.wander(level)
+	}
+}
+
+// flee runs from where the player was last seen, a cornered monster fights
+func (m *Monster) flee(level *Level) {
+	if next, found := level.fleeMap(m.LastSeen).Downhill(level, m.Pos, canWalk); found && next != level.Player.Pos {
+		m.Move(next, level)
+	} else {
+		m.fightBack(level)
+	}
+}
+
+// keepDistance stays between keepAway and shootRange from the player and shoots at it from there,
+// out of sight it goes looking like hunt does
+func (m *Monster) keepDistance(level *Level) {
+	if !m.seesPlayer {
+		m.hunt(level)
+		return
+	}
+	p := level.Player
+	dist := chebyshev(m.Pos, p.Pos)
+	switch {
+	case dist < keepAway && m.stepAway(level, p.Pos):
+	case dist <= shootRange:
+		level.attack(&m.Character, &p.Character)
+	case !m.stepTowards(level, p.Pos):
+		m.wander(level)
+	}
+}
+
+func chebyshev(a, b Pos) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+func distSquared(a, b Pos) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
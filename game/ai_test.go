@@ -0,0 +1,164 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+)
+
+// aiLevel is a fixture with a player on P and the random numbers seeded
+func aiLevel(t *testing.T, seed int64, rows ...string) (*Level, map[rune]Pos) {
+	t.Helper()
+	level, m := fixture(t, rows...)
+	level.Events = NewEventLog()
+	level.Player = testPlayer(m['P'], 1)
+	level.seedRandom(seed)
+	return level, m
+}
+
+func aiMonster(level *Level, pos Pos, behavior string) *Monster {
+	m := testMonster(level, "Rat", pos, 1, behavior)
+	m.Rune = 'r'
+	m.MaxHitpoints = m.Hitpoints
+	m.SightRange = 4
+	m.Damage = Dice{1, 2, 0}
+	return m
+}
+
+// act gives m the AP for one action and lets it take it
+func act(m *Monster, level *Level) {
+	m.AP = ActionThreshold
+	m.Update(level)
+}
+
+var aiRoom = []string{
+	"##############",
+	"#............#",
+	"#............#",
+	"#............#",
+	"##############",
+}
+
+func TestMonsterStates(t *testing.T) {
+	near, far := Pos{9, 2}, Pos{1, 1} // from the monster on 12,2
+	tests := []struct {
+		name     string
+		behavior string
+		player   Pos
+		setup    func(m *Monster, p *Player)
+		want     AIState
+		memory   int
+	}{
+		{"calm out of sight", "hunt", far, nil, Wander, 0},
+		{"hunts once it sees the player", "hunt", near, nil, Hunt, monsterMemory},
+		{"patrol hunts too", "patrol", near, nil, Hunt, monsterMemory},
+		{"keeps looking for a while", "hunt", far, func(m *Monster, p *Player) { m.Memory, m.LastSeen = 3, Pos{5, 2} }, Hunt, 2},
+		{"gives up", "hunt", far, func(m *Monster, p *Player) { m.Memory, m.LastSeen = 1, Pos{5, 2} }, Wander, 0},
+		{"runs when hurt", "hunt", near, func(m *Monster, p *Player) { m.Hitpoints = 2 }, Flee, monsterMemory},
+		{"hurt but calm", "hunt", far, func(m *Monster, p *Player) { m.Hitpoints = 2 }, Wander, 0},
+		{"coward runs straight away", "coward", near, nil, Flee, monsterMemory},
+		{"ranged keeps its distance", "ranged", near, nil, KeepDistance, monsterMemory},
+		{"idle stays idle", "idle", near, nil, Idle, monsterMemory},
+		{"dead player is forgotten", "hunt", near, func(m *Monster, p *Player) { p.Alive = false }, Wander, 0},
+	}
+	for _, tt := range tests {
+		level, _ := aiLevel(t, 1, aiRoom...)
+		level.Player.Pos = tt.player
+		m := aiMonster(level, Pos{12, 2}, tt.behavior)
+		if tt.setup != nil {
+			tt.setup(m, level.Player)
+		}
+		act(m, level)
+		if m.State != tt.want || m.Memory != tt.memory {
+			t.Errorf("%s: state %d memory %d, want %d and %d", tt.name, m.State, m.Memory, tt.want, tt.memory)
+		}
+		if m.seesPlayer && m.LastSeen != level.Player.Pos {
+			t.Errorf("%s: saw the player on %v but remembers %v", tt.name, level.Player.Pos, m.LastSeen)
+		}
+	}
+}
+
+func TestFleeRunsAway(t *testing.T) {
+	level, _ := aiLevel(t, 1, aiRoom...)
+	level.Player.Pos = Pos{5, 2}
+	m := aiMonster(level, Pos{7, 2}, "hunt")
+	m.Hitpoints = 1
+	for i := 0; i < 4; i++ {
+		before := chebyshev(m.Pos, level.Player.Pos)
+		act(m, level)
+		if m.State != Flee || chebyshev(m.Pos, level.Player.Pos) < before {
+			t.Fatalf("action %d: state %d, went from %d to %d away", i, m.State, before, chebyshev(m.Pos, level.Player.Pos))
+		}
+	}
+	if m.Pos.X <= 7 {
+		t.Errorf("ended up on %v, not further away from the player", m.Pos)
+	}
+}
+
+func TestKeepDistance(t *testing.T) {
+	level, _ := aiLevel(t, 1, aiRoom...)
+	level.Player.Pos = Pos{5, 2}
+	m := aiMonster(level, Pos{7, 2}, "ranged")
+
+	// too close, it backs off
+	act(m, level)
+	if m.Pos.X != 8 {
+		t.Errorf("ranged monster 2 from the player went to %v instead of backing off", m.Pos)
+	}
+
+	// far enough, it stays and shoots
+	m.Pos = Pos{9, 2}
+	level.Monsters = map[Pos]*Monster{m.Pos: m}
+	events := len(level.Events.Events)
+	act(m, level)
+	if m.Pos != (Pos{9, 2}) || len(level.Events.Events) == events || level.Events.Events[events].Source != m.Name {
+		t.Errorf("ranged monster 4 from the player went to %v and logged %v", m.Pos, level.Events.Events[events:])
+	}
+}
+
+func TestHuntFollowsLastSeen(t *testing.T) {
+	level, mk := aiLevel(t, 1,
+		"#########",
+		"#M......#",
+		"#######.#",
+		"#P.....L#",
+		"#########",
+	)
+	m := aiMonster(level, mk['M'], "hunt")
+	m.LastSeen, m.Memory = mk['L'], monsterMemory
+	dm := level.huntMap(mk['L'])
+
+	for i := 0; m.Pos != mk['L']; i++ {
+		if i == monsterMemory {
+			t.Fatalf("still on %v after %d actions", m.Pos, i)
+		}
+		before := dm.At(m.Pos)
+		act(m, level)
+		if m.State != Hunt || dm.At(m.Pos) != before-1 {
+			t.Fatalf("action %d: state %d, went from %d to %d from where the player was seen", i, m.State, before, dm.At(m.Pos))
+		}
+	}
+	// the player isn't there, it gives up
+	act(m, level)
+	if m.Memory != 0 {
+		t.Errorf("still remembers the player for %d actions after finding nothing", m.Memory)
+	}
+}
+
+func TestAIReproducible(t *testing.T) {
+	walk := func(seed int64) string {
+		level, _ := aiLevel(t, seed, aiRoom...)
+		m := aiMonster(level, Pos{6, 2}, "wander")
+		var positions []Pos
+		for i := 0; i < 30; i++ {
+			act(m, level)
+			positions = append(positions, m.Pos)
+		}
+		return fmt.Sprint(positions)
+	}
+	if walk(3) != walk(3) {
+		t.Error("the same seed wandered differently")
+	}
+	if walk(3) == walk(4) {
+		t.Error("different seeds wandered the same way")
+	}
+}
@@ -2,6 +2,9 @@ package game
 
 import (
 	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 )
 
 // type Combatant interface {
@@ -11,12 +14,125 @@ import (
 // 	SetHitpoints() int
 // }
 
-// Attack c1 attacks c2
-func Attack(c1, c2 *Character) []string {
-	var events []string
-	c2.Hitpoints -= c1.Strength
+// Dice is a roll like 2d4+1
+type Dice struct {
+	Count int
+	Sides int
+	Bonus int
+}
+
+// ParseDice reads dice written as NdS, NdS+B or NdS-B
+func ParseDice(s string) (Dice, error) {
+	var d Dice
+	s = strings.TrimSpace(s)
+
+	dIndex := strings.IndexByte(s, 'd')
+	if dIndex < 1 {
+		return d, fmt.Errorf("dice %q should look like 1d6 or 2d4+1", s)
+	}
+	count, err := strconv.Atoi(s[:dIndex])
+	if err != nil || count < 1 {
+		return d, fmt.Errorf("dice %q should start with how many dice to roll", s)
+	}
+
+	rest := s[dIndex+1:]
+	bonus := 0
+	if i := strings.IndexAny(rest, "+-"); i >= 0 {
+		bonus, err = strconv.Atoi(rest[i:])
+		if err != nil {
+			return d, fmt.Errorf("dice %q has a bad bonus", s)
+		}
+		rest = rest[:i]
+	}
+	sides, err := strconv.Atoi(rest)
+	if err != nil || sides < 1 {
+		return d, fmt.Errorf("dice %q needs at least one side", s)
+	}
+
+	return Dice{count, sides, bonus}, nil
+}
+
+func (d Dice) Roll(r *rand.Rand) int {
+	total := d.Bonus
+	for i := 0; i < d.Count; i++ {
+		total += r.Intn(d.Sides) + 1
+	}
+	return total
+}
+
+func (d Dice) String() string {
+	s := fmt.Sprintf("%dd%d", d.Count, d.Sides)
+	if d.Bonus > 0 {
+		s += fmt.Sprintf("+%d", d.Bonus)
+	} else if d.Bonus < 0 {
+		s += fmt.Sprint(d.Bonus)
+	}
+	return s
+}
+
+// Attack c1 attacks c2. A d20 plus the attacker's Accuracy has to reach 10 plus the defender's Defense.
+// A natural 1 always misses and a natural 20 always hits and rolls the damage dice twice
+func Attack(c1, c2 *Character, r *rand.Rand) []Event {
+	var events []Event
+	event := func(severity Severity, format string, a ...interface{}) Event {
+		return Event{Category: CombatEvent, Severity: severity, Source: c1.Name, Target: c2.Name, Text: fmt.Sprintf(format, a...)}
+	}
 	c1.AP--
-	events = append(events, fmt.Sprintf("%s attacked %s for %d damage", c1.Name, c2.Name, c1.Strength))
+
+	roll := r.Intn(20) + 1
+	critical := roll == 20
+	if roll == 1 {
+		events = append(events, event(Info, "%s fumbled their attack on %s", c1.Name, c2.Name))
+		return events
+	}
+	if !critical && roll+c1.Accuracy < 10+c2.Defense {
+		events = append(events, event(Info, "%s missed %s", c1.Name, c2.Name))
+		return events
+	}
+
+	damage := c1.Damage.Roll(r) + c1.Strength
+	if critical {
+		damage += c1.Damage.Roll(r)
+	}
+	if c1.Weapon != nil {
+		damage += c1.Weapon.Power
+	}
+	if c2.Armor != nil {
+		damage -= c2.Armor.Power
+	}
+
+	switch {
+	case damage <= 0:
+		events = append(events, event(Info, "%s hit %s but it glanced off", c1.Name, c2.Name))
+	case critical:
+		c2.Hitpoints -= damage
+		events = append(events, event(Critical, "%s critically hit %s for %d damage!", c1.Name, c2.Name, damage))
+	default:
+		c2.Hitpoints -= damage
+		events = append(events, event(Warning, "%s attacked %s for %d damage", c1.Name, c2.Name, damage))
+	}
 
 	return events
 }
+
+// attack runs Attack, logs what happened and cleans up if the defender died
+func (level *Level) attack(attacker, defender *Character) {
+	for _, e := range Attack(attacker, defender, level.R) {
+		level.Events.Add(e)
+	}
+
+	if defender.Hitpoints > 0 || !defender.Alive {
+		return
+	}
+
+	if defender == &level.Player.Character {
+		level.Player.die(fmt.Sprintf("killed by %s", attacker.Name))
+		level.Events.Add(Event{Category: DeathEvent, Severity: Critical, Source: attacker.Name, Target: defender.Name, Text: "you died"})
+		return
+	}
+
+	m, exists := level.Monsters[defender.Pos]
+	if exists && &m.Character == defender {
+		m.Dead(level)
+	}
+}
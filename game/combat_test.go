@@ -0,0 +1,79 @@
+package game
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestParseDice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Dice
+		ok   bool
+	}{
+		{"1d6", Dice{1, 6, 0}, true},
+		{"2d4+1", Dice{2, 4, 1}, true},
+		{"1d6-2", Dice{1, 6, -2}, true},
+		{" 3d8+10 ", Dice{3, 8, 10}, true},
+		{"d6", Dice{}, false},
+		{"1d0", Dice{}, false},
+		{"1d6+", Dice{}, false},
+		{"0d6", Dice{}, false},
+		{"1x6", Dice{}, false},
+		{"", Dice{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDice(tt.in)
+		if tt.ok != (err == nil) || got != tt.want {
+			t.Errorf("ParseDice(%q) = %v, %v, want %v ok %v", tt.in, got, err, tt.want, tt.ok)
+			continue
+		}
+		if tt.ok && got.String() != strings.TrimSpace(tt.in) {
+			t.Errorf("%v.String() = %q, want %q", got, got.String(), strings.TrimSpace(tt.in))
+		}
+	}
+}
+
+// the outcomes for seed 1 are pinned so any change to how Attack uses the random numbers shows up
+func TestAttackOutcomes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	armor := &Item{Kind: Armor, Power: 3}
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		attacker := &Character{Entity: Entity{Name: "Rat"}, Accuracy: 2, Damage: Dice{1, 4, 0}, Strength: 1}
+		defender := &Character{Entity: Entity{Name: "Player"}, Defense: 2, Hitpoints: 100, Armor: armor}
+		events := Attack(attacker, defender, r)
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %v", events)
+		}
+		e := events[0]
+		switch {
+		case strings.Contains(e.Text, "fumbled"):
+			counts["fumble"]++
+		case strings.Contains(e.Text, "missed"):
+			counts["miss"]++
+		case strings.Contains(e.Text, "glanced"):
+			counts["glance"]++
+		case e.Severity == Critical:
+			counts["crit"]++
+		default:
+			counts["hit"]++
+		}
+		if (e.Severity == Critical || e.Severity == Warning) != (defender.Hitpoints < 100) {
+			t.Fatalf("%q left the defender on %d hitpoints", e.Text, defender.Hitpoints)
+		}
+		if attacker.AP != -1 {
+			t.Fatalf("an attack should cost 1 AP, not %v", -attacker.AP)
+		}
+	}
+
+	// a d20 of 1 fumbles, 2 to 9 miss, 10 and up hit and 20 is a critical. 1d4+1 against 3 armor
+	// glances off half the time, so roughly 5%, 40%, 25%, 25% and 5%
+	want := map[string]int{"fumble": 523, "miss": 4050, "glance": 2525, "hit": 2441, "crit": 461}
+	for outcome, n := range want {
+		if counts[outcome] != n {
+			t.Errorf("%s: got %d, want %d", outcome, counts[outcome], n)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package game
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"sort"
+)
+
+//go:embed maps data
+var builtin embed.FS
+
+// Content is where the maps and data files come from, paths look like "maps/level1.map". It starts
+// out as the files built into the binary, main puts a directory on top of it with Overlay for mods
+var Content fs.FS = builtin
+
+// overlayFS looks for files in upper first and falls back to lower, directories list the files of both
+type overlayFS struct {
+	upper, lower fs.FS
+}
+
+// Overlay returns a filesystem where files in upper replace the ones at the same path in lower
+// and everything else in lower is still there
+func Overlay(upper, lower fs.FS) fs.FS {
+	return overlayFS{upper, lower}
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.upper.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	return o.lower.Open(name)
+}
+
+// ReadDir is what fs.Glob uses, without it only the upper directory would be listed
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, errUpper := fs.ReadDir(o.upper, name)
+	lower, errLower := fs.ReadDir(o.lower, name)
+	if errUpper != nil && errLower != nil {
+		return nil, errLower
+	}
+
+	entries := append([]fs.DirEntry{}, upper...)
+	inUpper := make(map[string]bool)
+	for _, e := range upper {
+		inUpper[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !inUpper[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
@@ -0,0 +1,107 @@
+package game
+
+import "fmt"
+
+type EventCategory int
+
+const (
+	SystemEvent EventCategory = iota
+	CombatEvent
+	DoorEvent
+	DeathEvent
+	ItemEvent
+)
+
+func (c EventCategory) String() string {
+	switch c {
+	case CombatEvent:
+		return "combat"
+	case DoorEvent:
+		return "door"
+	case DeathEvent:
+		return "death"
+	case ItemEvent:
+		return "item"
+	default:
+		return "system"
+	}
+}
+
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+// Event is a single line of the message log. Source and Target are the names of the entities
+// involved, empty when there aren't any
+type Event struct {
+	Turn     int
+	Category EventCategory
+	Severity Severity
+	Source   string
+	Target   string
+	Text     string
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%d: %s", e.Turn, e.Text)
+}
+
+// EventLog is the message log for the whole game, every level points at the same one
+type EventLog struct {
+	Events      []Event
+	Turn        int          // stamped on events as they come in, the scheduler keeps it up to date
+	subscribers []subscriber // in the order they subscribed
+	nextID      int
+}
+
+type subscriber struct {
+	id int
+	f  func(Event)
+}
+
+func NewEventLog() *EventLog {
+	return &EventLog{}
+}
+
+// Add stamps the current turn on e, keeps it and hands it to the subscribers
+func (log *EventLog) Add(e Event) {
+	e.Turn = log.Turn
+	log.Events = append(log.Events, e)
+	// a copy, a subscriber can unsubscribe itself or others while it's being called
+	for _, s := range append([]subscriber(nil), log.subscribers...) {
+		s.f(e)
+	}
+}
+
+// Subscribe calls f with every event added from now on until the returned func is called.
+// f runs on the game goroutine in the middle of whatever logged the event so it should be quick
+func (log *EventLog) Subscribe(f func(Event)) (unsubscribe func()) {
+	id := log.nextID
+	log.nextID++
+	log.subscribers = append(log.subscribers, subscriber{id, f})
+	return func() {
+		for i, s := range log.subscribers {
+			if s.id == id {
+				log.subscribers = append(log.subscribers[:i], log.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Last returns up to n of the newest events, skipping the newest skip of them. The UIs use it to scroll
+func (log *EventLog) Last(n, skip int) []Event {
+	end := len(log.Events) - skip
+	if end < 0 {
+		end = 0
+	}
+	start := end - n
+	if start < 0 {
+		start = 0
+	}
+	return log.Events[start:end]
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubscribers(t *testing.T) {
+	log := NewEventLog()
+	var calls []string
+	unsubA := log.Subscribe(func(Event) { calls = append(calls, "a") })
+	var unsubB func()
+	unsubB = log.Subscribe(func(Event) {
+		calls = append(calls, "b")
+		unsubB()
+	})
+	log.Subscribe(func(Event) { calls = append(calls, "c") })
+
+	log.Add(Event{Text: "one"})
+	log.Add(Event{Text: "two"})
+	unsubA()
+	unsubA()
+	log.Add(Event{Text: "three"})
+
+	want := []string{"a", "b", "c", "a", "c", "c"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("called %v, want %v", calls, want)
+	}
+	if len(log.subscribers) != 1 {
+		t.Errorf("%d subscribers left, want 1", len(log.subscribers))
+	}
+}
+
+// travelling subscribes and unsubscribes every time, none of them should stay behind
+func TestSubscribersDontPileUp(t *testing.T) {
+	log := NewEventLog()
+	for i := 0; i < 1000; i++ {
+		log.Subscribe(func(Event) {})()
+	}
+	if len(log.subscribers) != 0 {
+		t.Errorf("%d subscribers left after unsubscribing them all", len(log.subscribers))
+	}
+}
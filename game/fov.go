@@ -0,0 +1,184 @@
+package game
+
+import "math"
+
+// Field of view using symmetric shadowcasting (https://www.albertford.com/shadowcasting/).
+// The area around the origin is split into four quadrants which are scanned row by row going
+// outwards, walls cast shadows that shrink the part of the next row that is still worth scanning.
+// Slopes are kept as fractions so there's no floating point fuzz and if a can see b then b can see a
+
+type quadrant int
+
+const (
+	north quadrant = iota
+	east
+	south
+	west
+)
+
+// slope is num/den with den always above 0
+type slope struct {
+	num, den int
+}
+
+type fovRow struct {
+	depth      int
+	start, end slope
+}
+
+// transform turns a row depth and column in a quadrant into a map position
+func (q quadrant) transform(origin Pos, depth, col int) Pos {
+	switch q {
+	case north:
+		return Pos{origin.X + col, origin.Y - depth}
+	case south:
+		return Pos{origin.X + col, origin.Y + depth}
+	case east:
+		return Pos{origin.X + depth, origin.Y + col}
+	default:
+		return Pos{origin.X - depth, origin.Y + col}
+	}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// minCol and maxCol are the first and last columns of the row between its start and end slope,
+// rounding ties towards the middle of the row
+func (r fovRow) minCol() int {
+	// floor(depth*start + 0.5)
+	return floorDiv(2*r.depth*r.start.num+r.start.den, 2*r.start.den)
+}
+
+func (r fovRow) maxCol() int {
+	// ceil(depth*end - 0.5)
+	return -floorDiv(-(2*r.depth*r.end.num - r.end.den), 2*r.end.den)
+}
+
+// symmetric checks the centre of the tile is inside the row's slopes, floor tiles are only
+// revealed when this holds which is what makes the result symmetric
+func (r fovRow) symmetric(col int) bool {
+	return col*r.start.den >= r.depth*r.start.num && col*r.end.den <= r.depth*r.end.num
+}
+
+func (r fovRow) next() fovRow {
+	return fovRow{r.depth + 1, r.start, r.end}
+}
+
+func tileSlope(depth, col int) slope {
+	return slope{2*col - 1, 2 * depth}
+}
+
+// FOV returns every position that can be seen from origin within radius
+func (level *Level) FOV(origin Pos, radius int) map[Pos]bool {
+	visible := make(map[Pos]bool)
+	if !inRange(level, origin) {
+		return visible
+	}
+	visible[origin] = true
+
+	for q := north; q <= west; q++ {
+		level.scanRow(visible, q, origin, radius, fovRow{1, slope{-1, 1}, slope{1, 1}})
+	}
+	return visible
+}
+
+func (level *Level) scanRow(visible map[Pos]bool, q quadrant, origin Pos, radius int, row fovRow) {
+	if row.depth > radius {
+		return
+	}
+
+	inRadius := func(p Pos) bool {
+		dx, dy := p.X-origin.X, p.Y-origin.Y
+		return dx*dx+dy*dy <= radius*radius
+	}
+
+	prevWall := false
+	hasPrev := false
+	for col := row.minCol(); col <= row.maxCol(); col++ {
+		pos := q.transform(origin, row.depth, col)
+		wall := !canSeeThrough(level, pos)
+
+		if (wall || row.symmetric(col)) && inRange(level, pos) && inRadius(pos) {
+			visible[pos] = true
+		}
+		if hasPrev && prevWall && !wall {
+			row.start = tileSlope(row.depth, col)
+		}
+		if hasPrev && !prevWall && wall {
+			nextRow := row.next()
+			nextRow.end = tileSlope(row.depth, col)
+			level.scanRow(visible, q, origin, radius, nextRow)
+		}
+		prevWall = wall
+		hasPrev = true
+	}
+	if hasPrev && !prevWall {
+		level.scanRow(visible, q, origin, radius, row.next())
+	}
+}
+
+// lights returns every position lit by a light source, it's only worked out again after something
+// that blocks light changes
+func (level *Level) lights() map[Pos]bool {
+	if level.lit != nil {
+		return level.lit
+	}
+
+	level.lit = make(map[Pos]bool)
+	for y, row := range level.Level {
+		for x, t := range row {
+			if t.Light > 0 {
+				for pos := range level.FOV(Pos{x, y}, t.Light) {
+					level.lit[pos] = true
+				}
+			}
+		}
+	}
+	return level.lit
+}
+
+// viewDistance is how far away lit tiles can be seen from, anything on the level
+func (level *Level) viewDistance() int {
+	return len(level.Level) + len(level.Level[0])
+}
+
+// sightRange is how far c sees without light, a bright level lets it see further
+func (level *Level) sightRange(c *Character) int {
+	if level.Ambient > c.SightRange {
+		return level.Ambient
+	}
+	return c.SightRange
+}
+
+// VisibleFrom returns what c can see: everything within its SightRange plus lit tiles further out
+func (level *Level) VisibleFrom(c *Character) map[Pos]bool {
+	visible := level.FOV(c.Pos, level.sightRange(c))
+
+	lit := level.lights()
+	if len(lit) > 0 {
+		for pos := range level.FOV(c.Pos, level.viewDistance()) {
+			if lit[pos] {
+				visible[pos] = true
+			}
+		}
+	}
+	return visible
+}
+
+// CanSee reports whether c can see target, the same as VisibleFrom(c)[target]. It still works out a
+// field of view but only out as far as target, and not at all when target is dark and out of sight
+func (level *Level) CanSee(c *Character, target Pos) bool {
+	dx, dy := target.X-c.X, target.Y-c.Y
+	sight := level.sightRange(c)
+	if dx*dx+dy*dy > sight*sight && !level.lights()[target] {
+		return false
+	}
+	radius := int(math.Ceil(math.Sqrt(float64(dx*dx + dy*dy))))
+	return level.FOV(c.Pos, radius)[target]
+}
@@ -0,0 +1,152 @@
+package game
+
+import "testing"
+
+func TestFOVSymmetric(t *testing.T) {
+	level, _ := fixture(t,
+		"############",
+		"#....#.....#",
+		"#.##...#.#.#",
+		"#....#...#.#",
+		"#.#.....##.#",
+		"#...#.#....#",
+		"############",
+	)
+	radius := 8
+	var floors []Pos
+	for y, row := range level.Level {
+		for x := range row {
+			if canSeeThrough(level, Pos{x, y}) {
+				floors = append(floors, Pos{x, y})
+			}
+		}
+	}
+	fovs := make(map[Pos]map[Pos]bool)
+	for _, pos := range floors {
+		fovs[pos] = level.FOV(pos, radius)
+	}
+	for _, a := range floors {
+		for _, b := range floors {
+			if fovs[a][b] != fovs[b][a] {
+				t.Errorf("%v sees %v is %v but the other way round it's %v", a, b, fovs[a][b], fovs[b][a])
+			}
+		}
+	}
+}
+
+func TestFOVWalls(t *testing.T) {
+	level, m := fixture(t,
+		"#########",
+		"#.......#",
+		"#.A.#.B.#",
+		"#.......#",
+		"#########",
+	)
+	visible := level.FOV(m['A'], 10)
+	if visible[m['B']] {
+		t.Error("A sees B through the pillar")
+	}
+	if !visible[Pos{4, 2}] {
+		t.Error("the pillar itself isn't visible")
+	}
+	for _, pos := range []Pos{{0, 0}, {8, 4}, {4, 1}, {6, 1}} {
+		if !visible[pos] {
+			t.Errorf("A can't see %v", pos)
+		}
+	}
+	if visible[Pos{-1, 2}] || len(level.FOV(Pos{-1, 2}, 10)) != 0 {
+		t.Error("the field of view goes outside the level")
+	}
+}
+
+func TestFOVRadius(t *testing.T) {
+	level, m := fixture(t,
+		"...........",
+		"...........",
+		"...........",
+		"...........",
+		"...........",
+		".....O.....",
+		"...........",
+		"...........",
+		"...........",
+		"...........",
+		"...........",
+	)
+	o := m['O']
+	visible := level.FOV(o, 3)
+	for y, row := range level.Level {
+		for x := range row {
+			dx, dy := x-o.X, y-o.Y
+			if want := dx*dx+dy*dy <= 9; visible[Pos{x, y}] != want {
+				t.Errorf("%d,%d is %d,%d from the middle, visible %v", x, y, dx, dy, visible[Pos{x, y}])
+			}
+		}
+	}
+}
+
+func TestFOVDoors(t *testing.T) {
+	level, m := fixture(t,
+		"#########",
+		"#A..|..B#",
+		"#########",
+	)
+	level.Events = NewEventLog()
+	level.Player = &Player{Character: Character{Entity: Entity{Pos: m['A']}, SightRange: 10}}
+	door := Pos{4, 1}
+
+	visible := level.FOV(m['A'], 10)
+	if !visible[door] || visible[m['B']] {
+		t.Errorf("with the door closed: door visible %v, B visible %v", visible[door], visible[m['B']])
+	}
+	openDoor(level, door)
+	visible = level.FOV(m['A'], 10)
+	if !visible[door] || !visible[m['B']] {
+		t.Errorf("with the door open: door visible %v, B visible %v", visible[door], visible[m['B']])
+	}
+}
+
+func TestLights(t *testing.T) {
+	level, m := fixture(t,
+		"###################",
+		"#A.....|...L..*...#",
+		"#~~~~~~~~~~~~~~~~~#",
+		"###################",
+	)
+	level.Events = NewEventLog()
+	level.Player = &Player{Character: Character{Entity: Entity{Pos: m['A']}, SightRange: 2}}
+	c := &level.Player.Character
+	torch := Pos{14, 1}
+
+	lit := level.lights()
+	if !lit[m['L']] || lit[m['A']] {
+		t.Errorf("L lit %v, A lit %v, only L is within 5 of the torch", lit[m['L']], lit[m['A']])
+	}
+	for pos := range lit {
+		if dx, dy := pos.X-torch.X, pos.Y-torch.Y; dx*dx+dy*dy > 25 {
+			t.Errorf("%v is lit but it's too far from the torch", pos)
+		}
+	}
+
+	// the door is in the way until it's opened, then L can be seen from well outside A's SightRange
+	if level.VisibleFrom(c)[m['L']] || level.CanSee(c, m['L']) {
+		t.Error("A sees the lit L through the closed door")
+	}
+	openDoor(level, Pos{7, 1})
+	visible := level.VisibleFrom(c)
+	if !visible[m['L']] || !level.CanSee(c, m['L']) {
+		t.Error("A doesn't see the lit L with the door open")
+	}
+	if visible[Pos{5, 1}] {
+		t.Error("A sees a dark tile outside its SightRange")
+	}
+
+	// CanSee is a shortcut, it has to agree with VisibleFrom everywhere
+	for y, row := range level.Level {
+		for x := range row {
+			if pos := (Pos{x, y}); level.CanSee(c, pos) != visible[pos] {
+				t.Errorf("CanSee says %v for %v, VisibleFrom says %v", level.CanSee(c, pos), pos, visible[pos])
+			}
+		}
+	}
+}
@@ -1,16 +1,12 @@
 package game
 
 import (
-	"bufio"
-	"encoding/csv"
 	"fmt"
+	"io/fs"
 	"math"
 	"math/rand"
-	"os"
-	"path/filepath"
-	"strconv"
-
-	"github.com/davecgh/go-spew/spew"
+	"sort"
+	"strings"
 )
 
 type Game struct {
@@ -18,6 +14,12 @@ type Game struct {
 	InputChan    chan *Input
 	Levels       map[string]*Level
 	CurrentLevel *Level
+	Scheduler    Scheduler
+	Dungeon      DungeonParams
+	recorder     *Recorder
+	Events       *EventLog
+	travel       *travel
+	replaying    bool // inputs come from a replay, Run mustn't make up its own
 }
 
 type InputType int
@@ -36,18 +38,44 @@ const (
 	Inspect
 	QuitGame
 	CloseWindow
+	QuickSave
+	QuickLoad
+	PickUp
+	Drop
+	UseItem
+	Restart
+	OpenWindow
+	UpLeft
+	UpRight
+	DownLeft
+	DownRight
+	Travel
+	TravelStep
+	Hover
+	Explore
 
 	BloodVariantCount int = 12 // maybe I can get this from the atlas?
 )
 
+// moveDirections is how far each movement input moves the player
+var moveDirections = map[InputType]Pos{
+	Up:        {0, -1},
+	Down:      {0, 1},
+	Left:      {-1, 0},
+	Right:     {1, 0},
+	UpLeft:    {-1, -1},
+	UpRight:   {1, -1},
+	DownLeft:  {-1, 1},
+	DownRight: {1, 1},
+}
+
 type Input struct {
 	Type      InputType
-	MousePos  Pos
+	MapPos    *Pos // the map position under the mouse, worked out by the UI so the game doesn't depend on the camera
 	LevelChan chan *Level
+	ItemIndex int
 }
 
-var OffsetX, OffsetY int
-
 type Pos struct {
 	X, Y int
 }
@@ -63,26 +91,47 @@ type Character struct {
 	Type       string
 	Hitpoints  int
 	Strength   int
+	Defense    int
+	Accuracy   int
+	Damage     Dice
 	Speed      float64
 	SightRange int
 	AP         float64
 	Alive      bool
+	Weapon     *Item `json:"-"` // saved as inventory indexes, see save.go
+	Armor      *Item `json:"-"`
 }
 
 type Player struct {
 	Character
+	Inventory    []*Item
+	CauseOfDeath string
+	DeathTurn    int
 }
 
 type Level struct {
+	Name     string
 	Level    [][]Tile
 	Player   *Player
 	Monsters map[Pos]*Monster
+	Items    map[Pos][]*Item
 	StairMap map[Pos]*LevelPos
-	Events   []string
-	EventPos int
+	Events   *EventLog // shared by every level of the game
 	TileMap  map[rune]Tile
 	Debug    map[Pos]bool
 	R        *rand.Rand
+	src      *countingSource
+	lit      map[Pos]bool // worked out by lights
+	Path     []Pos        // travel path to show on the map
+
+	Description string
+	Music       string // the front end decides what to play for it
+	Ambient     int    // everyone on the level can see at least this far
+	Triggers    []*Trigger
+	markers     map[rune][]Pos // where each map character was, only kept while loading
+	stairLinks  []stairLink
+	huntMaps    map[Pos]*DijkstraMap // shared by the monsters hunting each spot, thrown away every tick
+	fleeMaps    map[Pos]*DijkstraMap // the same for running away
 }
 
 type LevelPos struct {
@@ -90,87 +139,110 @@ type LevelPos struct {
 	Pos
 }
 
-type priorityPos struct {
-	Pos
-	priority int
-}
+// DefaultMonsters is the monster file NewGame loads from Content when no monster types have been loaded
+const DefaultMonsters = "data/monsters.txt"
+
+// NewGame loads the hand made levels and then adds any generated ones asked for in dungeon. Problems
+// with the map files come back as a *MapError. If LoadMonsterTypes hasn't been called it loads
+// DefaultMonsters first, call it beforehand to use other monsters
+func NewGame(numWindows int, dungeon DungeonParams) (*Game, error) {
+	if len(MonsterTypes) == 0 {
+		if err := LoadMonsterTypes(DefaultMonsters); err != nil {
+			return nil, err
+		}
+	}
 
-func NewGame(numWindows int) *Game {
 	levelChans := make([]chan *Level, numWindows)
 	for i := range levelChans {
-		levelChans[i] = make(chan *Level)
-
+		levelChans[i] = NewViewerChan()
 	}
 	inputChan := make(chan *Input)
-	//TODO: need to better select the first level
 
-	game := &Game{LevelChans: levelChans, InputChan: inputChan, Levels: loadLevels()}
-	game.loadWorld()
+	levels, err := loadLevels(Content, dungeon.Seed)
+	if err != nil {
+		return nil, err
+	}
+	game := &Game{LevelChans: levelChans, InputChan: inputChan, Levels: levels, Dungeon: dungeon}
+	game.Events = NewEventLog()
+	err = game.loadWorld(Content, "maps/world.txt")
+	if err != nil {
+		return nil, err
+	}
+	err = game.linkStairs()
+	if err != nil {
+		return nil, err
+	}
+	err = game.addGeneratedLevels(dungeon)
+	if err != nil {
+		return nil, err
+	}
+	game.shareEvents()
 
-	return game
+	return game, nil
 }
 
 func (p *Pos) posToString() string {
 	return fmt.Sprintf("{%d, %d}", p.X, p.Y)
 }
 
-func (game *Game) loadWorld() {
-	file, err := os.Open("game/maps/world.txt")
-	if err != nil {
-		panic(err)
-	}
-	csvReader := csv.NewReader(file)
-	csvReader.FieldsPerRecord = -1
-	csvReader.TrimLeadingSpace = true
-	rows, err := csvReader.ReadAll()
+// sortPositions orders positions top to bottom, left to right. Map iteration order is random
+// so anything that has to be repeatable goes through this first
+func sortPositions(positions []Pos) {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].Y != positions[j].Y {
+			return positions[i].Y < positions[j].Y
+		}
+		return positions[i].X < positions[j].X
+	})
+}
+
+// loadWorld reads which level the game starts on from the first line of the world file,
+// every other line is stairs from one level to another
+func (game *Game) loadWorld(fsys fs.FS, path string) error {
+	lines, err := readLines(fsys, path)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	for rowIndex, row := range rows {
-		// set first level
-		if rowIndex == 0 {
-			game.CurrentLevel = game.Levels[row[0]]
+	started := false
+	for i, line := range lines {
+		lineNum := i + 1
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !started {
+			started = true
+			name := strings.TrimSpace(strings.Split(line, ",")[0])
+			game.CurrentLevel = game.Levels[name]
 			if game.CurrentLevel == nil {
-				fmt.Println("couldn't find currentlevel name in world file:", row[0])
-				panic(nil)
+				return &MapError{path, lineNum, fmt.Errorf("no level called %s to start on", name)}
 			}
 			continue
 		}
-		levelWithStairs := game.Levels[row[0]]
-		if levelWithStairs == nil {
-			fmt.Println("couldn't find level name 1 in world file")
-			panic(nil)
-		}
-		x, err := strconv.ParseInt(row[1], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		y, err := strconv.ParseInt(row[2], 10, 64)
-		if err != nil {
-			panic(err)
-		}
-		pos := Pos{int(x), int(y)}
 
-		levelToTeleportTo := game.Levels[row[3]]
-		if levelWithStairs == nil {
-			fmt.Println("couldn't find level name 2 in world file")
-			panic(nil)
-		}
-		x, err = strconv.ParseInt(row[4], 10, 64)
+		link, err := parseWorldLine(line)
 		if err != nil {
-			panic(err)
+			return &MapError{path, lineNum, err}
 		}
-		y, err = strconv.ParseInt(row[5], 10, 64)
-		if err != nil {
-			panic(err)
+		for _, name := range []string{link.from, link.to} {
+			if game.Levels[name] == nil {
+				return &MapError{path, lineNum, fmt.Errorf("no level called %s", name)}
+			}
+		}
+		levelWithStairs := game.Levels[link.from]
+		levelToTeleportTo := game.Levels[link.to]
+		if !inRange(levelWithStairs, link.fromPos) || !inRange(levelToTeleportTo, link.toPos) {
+			return &MapError{path, lineNum, fmt.Errorf("position outside of the level")}
 		}
-		posToClimbTo := Pos{int(x), int(y)}
-		levelWithStairs.StairMap[pos] = &LevelPos{levelToTeleportTo, posToClimbTo}
+		levelWithStairs.StairMap[link.fromPos] = &LevelPos{levelToTeleportTo, link.toPos}
 	}
+	if !started {
+		return &MapError{path, 0, fmt.Errorf("no level to start on")}
+	}
+	return nil
 }
 
-func loadLevels() map[string]*Level {
+func loadLevels(fsys fs.FS, seed int64) (map[string]*Level, error) {
 	newPlayer := &Player{
 		Character: Character{
 			Entity: Entity{
@@ -181,6 +253,9 @@ func loadLevels() map[string]*Level {
 			Type:       "Player",
 			Hitpoints:  50,
 			Strength:   3,
+			Defense:    1,
+			Accuracy:   2,
+			Damage:     Dice{1, 4, 0},
 			Speed:      1.0,
 			SightRange: 5,
 			Alive:      true,
@@ -190,88 +265,25 @@ func loadLevels() map[string]*Level {
 
 	levels := make(map[string]*Level)
 
-	filenames, err := filepath.Glob("game/maps/*.map")
+	files, err := mapFiles(fsys)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	for _, fileName := range filenames {
-
-		levelName := fileName[len(filepath.Base(fileName)) : len(fileName)-len(filepath.Ext(fileName))] // Readable? Nah... It just extracts the filename from the path
-		file, err := os.Open(fileName)
+	for _, file := range files {
+		mf, err := parseMapFile(file.fsys, file.path)
 		if err != nil {
-			panic(err)
-		}
-		defer file.Close()
-
-		level := &Level{}
-		level.Debug = make(map[Pos]bool)
-		level.Events = make([]string, 10)
-		level.R = rand.New(rand.NewSource(1))
-		level.StairMap = make(map[Pos]*LevelPos)
-		scanner := bufio.NewScanner(file)
-		zoneRows := make([]string, 0)
-		longestRow := 0
-		index := 0
-
-		for scanner.Scan() {
-			zoneRows = append(zoneRows, scanner.Text())
-			if len(zoneRows[index]) > longestRow {
-				longestRow = len(zoneRows[index])
-			}
-			index++
+			return nil, err
 		}
-		level.Level = make([][]Tile, len(zoneRows))
-
-		level.Player = newPlayer
-
-		level.Monsters = make(map[Pos]*Monster)
-		level.LoadTileMap()
-
-		for i := range level.Level {
-			level.Level[i] = make([]Tile, longestRow)
+		level, err := mf.buildLevel(newPlayer, seed)
+		if err != nil {
+			return nil, err
 		}
-		for y := 0; y < len(level.Level); y++ {
-			line := zoneRows[y]
-			for x, r := range line {
-				var t Tile
-				switch r {
-				case ' ', '\n', '\t', '\r':
-					t = level.TileMap[Empty]
-				case '#':
-					t = level.TileMap[r]
-				case '.':
-					t = level.TileMap[r]
-				case '|':
-					t = level.TileMap[r]
-				case '/':
-					t = level.TileMap[r]
-				case '@':
-					level.Player.X = x
-					level.Player.Y = y
-					t = level.TileMap[DirtFloor]
-				case 'R':
-					p := Pos{X: x, Y: y}
-					level.Monsters[p] = NewRat(p)
-					t = level.TileMap[DirtFloor]
-				case 'S':
-					p := Pos{X: x, Y: y}
-					level.Monsters[p] = NewSpider(p)
-					t = level.TileMap[DirtFloor]
-				case '~':
-					t = level.TileMap[r]
-				case 'u':
-					t = level.TileMap[r]
-				case 'd':
-					t = level.TileMap[r]
-				default:
-					panic(fmt.Sprintf("Invalid rune '%s' in map at position [%d,%d]", string(r), y+1, x+1))
-				}
-				level.Level[y][x] = t
-			}
+		if levels[level.Name] != nil {
+			return nil, &MapError{file.path, 0, fmt.Errorf("there is already a level called %s", level.Name)}
 		}
-		levels[levelName] = level
+		levels[level.Name] = level
 	}
-	return levels
+	return levels, nil
 }
 
 func canWalk(level *Level, pos Pos) bool {
@@ -298,30 +310,26 @@ func canSeeThrough(level *Level, pos Pos) bool {
 	return false
 }
 
+// lineOfSight clears the old view and marks everything the player can see now
 func (level *Level) lineOfSight() {
-	pos := level.Player.Pos
-	dist := level.Player.SightRange
-
-	for y := pos.Y - dist; y <= pos.Y+dist; y++ {
-		for x := pos.X - dist; x <= pos.X+dist; x++ {
-			xDelta := pos.X - x
-			yDelta := pos.Y - y
-			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
-			if d <= float64(dist) {
-				zone := level.bresenham(pos, Pos{x, y})
-				for _, pos := range zone {
-					level.TileAtPos(pos).Visible = true
-					level.TileAtPos(pos).Seen = true
-				}
-			}
+	for y := range level.Level {
+		for x := range level.Level[y] {
+			level.Level[y][x].Visible = false
 		}
 	}
+
+	for pos := range level.VisibleFrom(&level.Player.Character) {
+		level.TileAtPos(pos).Visible = true
+		level.TileAtPos(pos).Seen = true
+	}
 }
 
 func openDoor(level *Level, pos Pos) {
 	t := level.Level[pos.Y][pos.X]
 	if t.Rune == ClosedDoor {
 		level.Level[pos.Y][pos.X] = level.TileMap[OpenDoor]
+		level.Events.Add(Event{Category: DoorEvent, Source: level.Player.Name, Text: fmt.Sprintf("%s opened a door", level.Player.Name)})
+		level.lit = nil // light can get through now
 		level.lineOfSight()
 		level.Player.AP--
 	}
@@ -332,24 +340,24 @@ func (g *Game) Move(level *Level, to Pos) {
 	if inRange(g.CurrentLevel, to) {
 		p := level.Player
 		tile := level.TileAtPos(to)
-		if p.AP >= float64(tile.Cost) {
+		if p.AP >= ActionThreshold {
 			stairs := level.StairMap[to]
 			if stairs != nil {
 				g.CurrentLevel = stairs.Level
 				g.CurrentLevel.Player.Pos = stairs.Pos
 				p.AP -= float64(tile.Cost)
 				g.CurrentLevel.lineOfSight()
+				if g.CurrentLevel.Description != "" {
+					g.CurrentLevel.Events.Add(Event{Category: SystemEvent, Text: g.CurrentLevel.Description})
+				}
+				g.CurrentLevel.fireTrigger(p.Pos)
 			} else {
 				_, exists := level.Monsters[to]
 				if !exists {
 					p.Pos = to
 					p.AP -= float64(tile.Cost)
-					for x, row := range level.Level {
-						for y := range row {
-							level.Level[x][y].Visible = false
-						}
-					}
 					level.lineOfSight()
+					level.fireTrigger(to)
 				}
 			}
 		}
@@ -365,16 +373,15 @@ func (p *Player) Action(level *Level, target Pos) {
 
 	m, exists := level.Monsters[target]
 	if exists {
-		events := Attack(&p.Character, &m.Character)
-		level.AddEvents(events...)
-
-		if p.Hitpoints <= 0 {
-			level.AddEvents("you died")
-			p.Alive = false
-		}
+		level.attack(&p.Character, &m.Character)
 	}
 }
 
+func (p *Player) die(cause string) {
+	p.Alive = false
+	p.CauseOfDeath = cause
+}
+
 func inRange(level *Level, pos Pos) bool {
 	return int(pos.X) < len(level.Level[0]) && int(pos.Y) < len(level.Level) && pos.X >= 0 && pos.Y >= 0
 }
@@ -383,34 +390,17 @@ func (g *Game) handleInput(input *Input) {
 	level := g.CurrentLevel
 	p := level.Player
 	switch input.Type {
-	case Up:
-		pos := Pos{p.X, p.Y - 1}
-		if canWalk(level, pos) {
-			g.Move(level, pos)
-		} else {
-			p.Action(level, pos)
+	case Up, Down, Left, Right, UpLeft, UpRight, DownLeft, DownRight:
+		d := moveDirections[input.Type]
+		pos := Pos{p.X + d.X, p.Y + d.Y}
+		if !canStep(level, p.Pos, pos) {
+			break
 		}
-	case Down:
-		pos := Pos{p.X, p.Y + 1}
 		if canWalk(level, pos) {
 			g.Move(level, pos)
 		} else {
 			p.Action(level, pos)
 		}
-	case Left:
-		pos := Pos{p.X - 1, p.Y}
-		if canWalk(level, pos) {
-			g.Move(level, pos)
-		} else {
-			p.Action(level, pos)
-		}
-	case Right:
-		pos := Pos{p.X + 1, p.Y}
-		if canWalk(level, pos) {
-			g.Move(level, pos)
-		} else {
-			p.Action(level, Pos{p.X + 1, p.Y})
-		}
 	case Camera_Up:
 
 	case Camera_Down:
@@ -420,41 +410,83 @@ func (g *Game) handleInput(input *Input) {
 	case Camera_Right:
 
 	case Search:
-		target := screenToWorldPos(input.MousePos)
+		target := *input.MapPos
 		t := level.TileAtPos(target)
 		if t.Rune == ClosedDoor {
 			openDoor(level, target)
 		}
 	case Inspect:
-		target := screenToWorldPos(input.MousePos)
-		spew.Dump(level.TileAtPos(target))
-
-		m, exists := level.Monsters[target]
-		if exists {
-			spew.Dump(m)
-		}
-	case CloseWindow:
-		close(input.LevelChan)
-		chanIndex := 0
-		for i, c := range g.LevelChans {
-			if c == input.LevelChan {
-				chanIndex = i
-				break
-			}
+		level.inspect(*input.MapPos)
+	case Travel:
+		g.startTravel(*input.MapPos)
+	case TravelStep:
+		g.travelStep()
+	case Explore:
+		g.startExplore()
+	case PickUp:
+		p.pickUp(level)
+	case Drop:
+		p.drop(level, input.ItemIndex)
+	case UseItem:
+		p.useItem(level, input.ItemIndex)
+	case QuickSave, QuickLoad:
+		if g.recorder != nil || g.replaying {
+			// the quicksave on disk isn't part of the replay, loading it would play out differently
+			// every time and playing a replay back mustn't overwrite it
+			level.Events.Add(Event{Category: SystemEvent, Severity: Warning, Text: "saving and loading are turned off while recording or replaying"})
+		} else if input.Type == QuickSave {
+			g.quickSave(level)
+		} else {
+			g.quickLoad(level)
 		}
-		g.LevelChans = append(g.LevelChans[:chanIndex], g.LevelChans[chanIndex+1:]...)
-		g.LevelChans = append(g.LevelChans[:chanIndex], g.LevelChans[chanIndex+1:]...)
 	case None:
 		break
 	}
 }
 
+// inspect says in the message log what's at pos, as long as the player has seen it
+func (level *Level) inspect(pos Pos) {
+	if !inRange(level, pos) || !level.TileAtPos(pos).Seen {
+		return
+	}
+	tile := level.TileAtPos(pos)
+	text := "that's " + tile.Name
+	if tile.Name == "" {
+		text = "that's " + tile.Type
+	}
+	if m, exists := level.Monsters[pos]; exists && tile.Visible {
+		text = fmt.Sprintf("that's %s with %d hitpoints", m.Name, m.Hitpoints)
+	}
+	level.Events.Add(Event{Category: SystemEvent, Text: text})
+}
+
+func (g *Game) quickSave(level *Level) {
+	err := g.SaveGame(QuickSavePath)
+	if err != nil {
+		level.Events.Add(Event{Category: SystemEvent, Severity: Warning, Text: "couldn't save the game: " + err.Error()})
+	} else {
+		level.Events.Add(Event{Category: SystemEvent, Text: "game saved"})
+	}
+}
+
+func (g *Game) quickLoad(level *Level) {
+	err := g.LoadGame(QuickSavePath)
+	if err != nil {
+		level.Events.Add(Event{Category: SystemEvent, Severity: Warning, Text: "couldn't load the game: " + err.Error()})
+	} else {
+		g.CurrentLevel.Events.Add(Event{Category: SystemEvent, Text: "game loaded"})
+	}
+}
+
 func (p *Player) GetStatStrings() []string {
 	var stats []string
 
 	stats = append(stats, "Name: "+p.Name)
 	stats = append(stats, "HP: "+fmt.Sprint(p.Hitpoints))
 	stats = append(stats, "Str: "+fmt.Sprint(p.Strength))
+	stats = append(stats, "Def: "+fmt.Sprint(p.Defense))
+	stats = append(stats, "Acc: "+fmt.Sprint(p.Accuracy))
+	stats = append(stats, "Dmg: "+p.Damage.String())
 	stats = append(stats, "Spd: "+fmt.Sprint(int(p.Speed)))
 	stats = append(stats, "AP: "+fmt.Sprint(int(p.AP)))
 	stats = append(stats, "Pos: "+p.posToString())
@@ -462,71 +494,122 @@ func (p *Player) GetStatStrings() []string {
 	return stats
 }
 
-func (level *Level) AddEvents(events ...string) {
-	for _, event := range events {
-		level.Events[level.EventPos] = event
-
-		level.EventPos++
-		if level.EventPos == len(level.Events) {
-			level.EventPos = 0
-		}
-
+// shareEvents points every level at the game's event log
+func (g *Game) shareEvents() {
+	for _, level := range g.Levels {
+		level.Events = g.Events
 	}
 }
 
-func screenToWorldPos(pos Pos) Pos {
-	return Pos{(pos.X - OffsetX) / 32, (pos.Y - OffsetY) / 32}
-}
-
 //  apparently ambit means range
 func (e *Entity) InRange(ambit int, p Pos) bool {
 	dist := int(math.Abs(float64(p.X-e.X) + float64(e.Y-p.Y)))
-	fmt.Printf("e.Pos: %s p.Pos: %s\n", e.posToString(), p.posToString())
-	fmt.Printf("formula: (%d - %d) + (%d - %d)\n", e.X, p.X, e.Y, p.Y)
-	fmt.Printf("calc dist: %d\n", dist)
-	fmt.Printf("in range: %v\n", dist <= ambit)
 	return dist <= ambit
 }
 
-func (g *Game) Run() {
-	fmt.Println("Starting...")
-
-	for _, lchan := range g.LevelChans {
-		lchan <- g.CurrentLevel
-	}
+// start gets the current level ready for the first input
+func (g *Game) start() {
 	count := 1
-	for _, m := range g.CurrentLevel.Monsters {
+	for _, m := range g.CurrentLevel.monstersInOrder() {
 		m.Name = m.Name + " " + fmt.Sprint(count)
 		count++
 	}
 
 	g.CurrentLevel.lineOfSight()
+	g.Scheduler.Advance(g.CurrentLevel)
+}
+
+// restart throws away every level and builds them again from scratch, the channels stay the same
+func (g *Game) restart() {
+	fresh, err := NewGame(0, g.Dungeon)
+	if err != nil {
+		// the same files loaded fine when the game started, somebody changed them since
+		g.Events.Add(Event{Category: SystemEvent, Severity: Critical, Text: "couldn't restart: " + err.Error()})
+		return
+	}
+	g.Levels = fresh.Levels
+	g.CurrentLevel = fresh.CurrentLevel
+	g.Scheduler = Scheduler{}
+	g.Events.Turn = 0
+	g.shareEvents()
+	g.start()
+	g.Events.Add(Event{Category: SystemEvent, Text: "started a new game"})
+}
 
-	for input := range g.InputChan {
-		if input.Type == QuitGame {
+// Run plays the game until it's quit or every window is closed. The error is from writing the
+// replay when the game is being recorded
+func (g *Game) Run() (err error) {
+	g.start()
+	g.broadcast()
+	defer func() {
+		err = g.stopRecording()
+	}()
+
+	for {
+		input, ok := g.nextInput()
+		if !ok {
 			return
 		}
-		g.CurrentLevel.Debug = map[Pos]bool{}
-
-		if g.CurrentLevel.Player.Alive {
-			for _, monster := range g.CurrentLevel.Monsters {
-				if monster.isPlayerInRange(g.CurrentLevel) {
-					fmt.Println(monster.Name)
-					fmt.Println("---------")
-					monster.Update(g.CurrentLevel)
-				}
-			}
+		if input.MapPos == nil {
+			// keys aren't anywhere on the map, replays still want something to write down
+			input.MapPos = &Pos{}
+		}
+		switch input.Type {
+		case OpenWindow, CloseWindow, QuitGame, Hover:
+		default:
+			g.record(input)
+		}
 
-			g.handleInput(input)
+		switch input.Type {
+		case Hover:
+			g.hover(*input.MapPos)
+			g.broadcast()
+			continue
+		case TravelStep:
+		default:
+			// doing anything else cuts travelling short
+			g.stopTravel()
 		}
 
-		if len(g.LevelChans) == 0 {
+		switch input.Type {
+		case QuitGame:
 			return
+		case OpenWindow:
+			g.addViewer(input.LevelChan)
+			g.broadcast()
+			continue
+		case CloseWindow:
+			g.removeViewer(input.LevelChan)
+			if len(g.LevelChans) == 0 {
+				return
+			}
+			continue
 		}
-		for _, lchan := range g.LevelChans {
-			lchan <- g.CurrentLevel
+		g.CurrentLevel.Debug = map[Pos]bool{}
+
+		p := g.CurrentLevel.Player
+		if p.Alive {
+			ap := p.AP
+			g.handleInput(input)
+
+			// only inputs that cost something move the world on, loading swaps the player out entirely
+			if g.CurrentLevel.Player == p && p.AP < ap {
+				g.Scheduler.Advance(g.CurrentLevel)
+			}
+			g.checkTravel()
+			if !p.Alive {
+				p.DeathTurn = g.Scheduler.Turn
+			}
+		} else {
+			// game over, all that's left is starting again or going back to a save
+			switch input.Type {
+			case Restart:
+				g.restart()
+			case QuickLoad:
+				g.handleInput(input)
+			}
 		}
-		fmt.Println(g.CurrentLevel.Debug)
-		g.CurrentLevel.Player.AP += g.CurrentLevel.Player.Speed
+
+		g.broadcast()
 	}
 }
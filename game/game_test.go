@@ -0,0 +1,99 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestGame starts from the built in monsters every time in case a test changed them
+func newTestGame(t testing.TB, numWindows int) *Game {
+	t.Helper()
+	if err := LoadMonsterTypes(DefaultMonsters); err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewGame(numWindows, DefaultDungeonParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestNewGameLoadsDefaultMonsters(t *testing.T) {
+	defer func(types map[rune]*MonsterType) { MonsterTypes = types }(MonsterTypes)
+	MonsterTypes = make(map[rune]*MonsterType)
+
+	g, err := NewGame(0, DefaultDungeonParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if MonsterTypes['S'] == nil || len(g.Levels["level2"].Monsters) == 0 {
+		t.Error("the built in monsters weren't loaded")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	g := newTestGame(t, 0)
+	g.start()
+	level := g.CurrentLevel
+	p := level.Player.Pos
+
+	level.inspect(p)
+	level.inspect(Pos{-1, -1})
+unseen:
+	for y, row := range level.Level {
+		for x := range row {
+			if !row[x].Seen {
+				level.inspect(Pos{x, y})
+				break unseen
+			}
+		}
+	}
+	events := level.Events.Events
+	if len(events) != 1 || events[0].Text != "that's "+level.TileAtPos(p).Name {
+		t.Errorf("inspecting the player's tile, outside the level and an unseen tile logged %v", events)
+	}
+}
+
+// the UI works out the map position, Run has to use it as it is
+func TestRunUsesMapPos(t *testing.T) {
+	g := newTestGame(t, 1)
+	viewer := g.LevelChans[0]
+	level := g.CurrentLevel
+	level.lineOfSight()
+	var target *Pos
+	for y, row := range level.Level {
+		for x, tile := range row {
+			pos := Pos{x, y}
+			if target == nil && tile.Visible && canWalk(level, pos) && chebyshev(pos, level.Player.Pos) >= 2 {
+				target = &pos
+			}
+		}
+	}
+	if target == nil {
+		t.Fatal("nothing in sight to hover over")
+	}
+
+	done := make(chan bool)
+	go func() {
+		g.Run()
+		done <- true
+	}()
+	g.InputChan <- &Input{Type: Hover, MapPos: target}
+	// the first snapshot is from before the hover
+	var path []Pos
+	timeout := time.After(time.Second)
+	for len(path) == 0 {
+		select {
+		case snap := <-viewer:
+			path = snap.Path
+		case <-timeout:
+			t.Fatal("no path showed up")
+		}
+	}
+	g.RemoveViewer(viewer)
+	<-done
+
+	if path[len(path)-1] != *target {
+		t.Errorf("hovering over %v showed the path %v", *target, path)
+	}
+}
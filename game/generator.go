@@ -0,0 +1,337 @@
+package game
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+// DungeonParams controls the levels made by GenerateLevel. The same params always give the same dungeon
+type DungeonParams struct {
+	Seed     int64 // also seeds the random numbers of the hand made levels
+	Depth    int   // number of generated levels below the hand made ones, 0 turns the generator off
+	Width    int
+	Height   int
+	Rooms    int // how many rooms to try to place, overlapping ones are thrown away
+	Monsters int // monsters on the first generated level, each level down adds one more
+}
+
+func DefaultDungeonParams() DungeonParams {
+	return DungeonParams{
+		Seed:     1,
+		Width:    60,
+		Height:   40,
+		Rooms:    20,
+		Monsters: 4,
+	}
+}
+
+// check catches params GenerateLevel can't do anything with, like the ones from bad command line flags
+func (params DungeonParams) check() error {
+	switch {
+	case params.Width < 1 || params.Height < 1:
+		return fmt.Errorf("generated levels can't be %dx%d", params.Width, params.Height)
+	case params.Rooms < 1:
+		return fmt.Errorf("generated levels need at least 1 room, not %d", params.Rooms)
+	case params.Monsters < 0:
+		return fmt.Errorf("generated levels can't have %d monsters", params.Monsters)
+	}
+	return nil
+}
+
+type room struct {
+	x, y, w, h int
+}
+
+func (rm room) center() Pos {
+	return Pos{rm.x + rm.w/2, rm.y + rm.h/2}
+}
+
+// overlaps checks against the room grown by one tile so rooms never share walls
+func (rm room) overlaps(other room) bool {
+	return rm.x-1 <= other.x+other.w && rm.x+rm.w+1 >= other.x &&
+		rm.y-1 <= other.y+other.h && rm.y+rm.h+1 >= other.y
+}
+
+func (rm room) randomPos(r *rand.Rand) Pos {
+	return Pos{rm.x + 1 + r.Intn(rm.w-2), rm.y + 1 + r.Intn(rm.h-2)}
+}
+
+// levelSeed mixes depth into the dungeon seed. Adding them up would make seed 1 at depth 2 the same
+// level as seed 2 at depth 1, hashing them keeps every seed and depth apart
+func levelSeed(seed int64, depth int) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, [2]int64{seed, int64(depth)})
+	return int64(h.Sum64())
+}
+
+// GenerateLevel builds a level out of rooms joined by corridors. depth starts at 1 for the first
+// generated level and is mixed into the seed so every level is different
+func GenerateLevel(name string, params DungeonParams, depth int, player *Player) (*Level, error) {
+	if err := params.check(); err != nil {
+		return nil, err
+	}
+	r := rand.New(rand.NewSource(levelSeed(params.Seed, depth)))
+
+	level := &Level{}
+	level.Name = name
+	level.Player = player
+	level.Debug = make(map[Pos]bool)
+	// the level's own random numbers come off the layout's so the two don't run in step
+	level.seedRandom(r.Int63())
+	level.StairMap = make(map[Pos]*LevelPos)
+	level.Monsters = make(map[Pos]*Monster)
+	level.Items = make(map[Pos][]*Item)
+	level.LoadTileMap()
+
+	level.Level = make([][]Tile, params.Height)
+	for y := range level.Level {
+		level.Level[y] = make([]Tile, params.Width)
+		for x := range level.Level[y] {
+			level.Level[y][x] = level.TileMap[StoneWall]
+		}
+	}
+
+	var rooms []room
+	for i := 0; i < params.Rooms; i++ {
+		w := 5 + r.Intn(8)
+		h := 5 + r.Intn(6)
+		if w >= params.Width-2 || h >= params.Height-2 {
+			continue
+		}
+		rm := room{1 + r.Intn(params.Width-w-1), 1 + r.Intn(params.Height-h-1), w, h}
+
+		overlapping := false
+		for _, other := range rooms {
+			if rm.overlaps(other) {
+				overlapping = true
+				break
+			}
+		}
+		if !overlapping {
+			rooms = append(rooms, rm)
+		}
+	}
+	if len(rooms) == 0 {
+		return nil, fmt.Errorf("couldn't fit any rooms in a %dx%d level", params.Width, params.Height)
+	}
+
+	// room walls are remembered so corridors going through them can become doors
+	roomWall := make(map[Pos]bool)
+	for _, rm := range rooms {
+		for y := rm.y; y < rm.y+rm.h; y++ {
+			for x := rm.x; x < rm.x+rm.w; x++ {
+				if x == rm.x || y == rm.y || x == rm.x+rm.w-1 || y == rm.y+rm.h-1 {
+					roomWall[Pos{x, y}] = true
+				} else {
+					level.Level[y][x] = level.TileMap[DirtFloor]
+				}
+			}
+		}
+	}
+
+	for i := 1; i < len(rooms); i++ {
+		level.carveCorridor(r, rooms[i-1].center(), rooms[i].center())
+	}
+	level.placeDoors(r, roomWall)
+
+	// a pool in roughly every third room
+	for _, rm := range rooms {
+		if r.Intn(3) == 0 {
+			level.placePool(r, rm)
+		}
+	}
+
+	upstairs := rooms[0].randomPos(r)
+	level.Level[upstairs.Y][upstairs.X] = level.TileMap[UpStairs]
+	if depth < params.Depth {
+		// with only one room the two can land on the same spot, there's always room for both
+		downstairs := rooms[len(rooms)-1].randomPos(r)
+		for downstairs == upstairs {
+			downstairs = rooms[len(rooms)-1].randomPos(r)
+		}
+		level.Level[downstairs.Y][downstairs.X] = level.TileMap[DownStairs]
+	}
+
+	// monsters stay out of the first room so the player doesn't arrive next to them
+	monsterCount := params.Monsters + depth - 1
+	monsterTypes := monsterTypeList()
+	for i := 0; i < monsterCount && len(rooms) > 1 && len(monsterTypes) > 0; i++ {
+		pos := rooms[1+r.Intn(len(rooms)-1)].randomPos(r)
+		if level.Level[pos.Y][pos.X].Rune != DirtFloor {
+			continue
+		}
+		if _, exists := level.Monsters[pos]; exists {
+			continue
+		}
+		level.Monsters[pos] = NewMonster(monsterTypes[r.Intn(len(monsterTypes))], pos)
+	}
+
+	if r.Intn(2) == 0 {
+		pos := rooms[r.Intn(len(rooms))].randomPos(r)
+		if level.Level[pos.Y][pos.X].Rune == DirtFloor {
+			level.addItem(NewHealthPotion(pos))
+		}
+	}
+
+	level.hideBuriedWalls()
+
+	return level, nil
+}
+
+// carveCorridor digs an L shaped corridor, randomly going across or down first
+func (level *Level) carveCorridor(r *rand.Rand, from, to Pos) {
+	corner := Pos{to.X, from.Y}
+	if r.Intn(2) == 0 {
+		corner = Pos{from.X, to.Y}
+	}
+
+	for _, leg := range [][2]Pos{{from, corner}, {corner, to}} {
+		pos := leg[0]
+		for {
+			if level.Level[pos.Y][pos.X].Rune == StoneWall {
+				level.Level[pos.Y][pos.X] = level.TileMap[DirtFloor]
+			}
+			if pos == leg[1] {
+				break
+			}
+			pos.X += sign(leg[1].X - pos.X)
+			pos.Y += sign(leg[1].Y - pos.Y)
+		}
+	}
+}
+
+// placeDoors puts doors where a corridor cut through a room wall and there is wall on both sides
+func (level *Level) placeDoors(r *rand.Rand, roomWall map[Pos]bool) {
+	for y := 1; y < len(level.Level)-1; y++ {
+		for x := 1; x < len(level.Level[y])-1; x++ {
+			pos := Pos{x, y}
+			if !roomWall[pos] || level.Level[y][x].Rune != DirtFloor {
+				continue
+			}
+			horizontalWalls := level.Level[y][x-1].Rune == StoneWall && level.Level[y][x+1].Rune == StoneWall
+			verticalWalls := level.Level[y-1][x].Rune == StoneWall && level.Level[y+1][x].Rune == StoneWall
+			if (horizontalWalls || verticalWalls) && r.Intn(2) == 0 {
+				level.Level[y][x] = level.TileMap[ClosedDoor]
+			}
+		}
+	}
+}
+
+func (level *Level) placePool(r *rand.Rand, rm room) {
+	if rm.w < 6 || rm.h < 6 {
+		return
+	}
+	center := rm.randomPos(r)
+	radius := 1 + r.Intn(2)
+	for y := center.Y - radius; y <= center.Y+radius; y++ {
+		for x := center.X - radius; x <= center.X+radius; x++ {
+			if x <= rm.x || y <= rm.y || x >= rm.x+rm.w-1 || y >= rm.y+rm.h-1 {
+				continue
+			}
+			dx, dy := x-center.X, y-center.Y
+			if dx*dx+dy*dy <= radius*radius && level.Level[y][x].Rune == DirtFloor {
+				level.Level[y][x] = level.TileMap[Water]
+			}
+		}
+	}
+}
+
+// hideBuriedWalls turns walls that don't touch anything walkable into empty space like the hand made maps
+func (level *Level) hideBuriedWalls() {
+	buried := make([]Pos, 0)
+	for y, row := range level.Level {
+		for x, t := range row {
+			if t.Rune != StoneWall {
+				continue
+			}
+			nextToFloor := false
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					p := Pos{x + dx, y + dy}
+					if inRange(level, p) && level.TileAtPos(p).HasFloor {
+						nextToFloor = true
+					}
+				}
+			}
+			if !nextToFloor {
+				buried = append(buried, Pos{x, y})
+			}
+		}
+	}
+	for _, p := range buried {
+		level.Level[p.Y][p.X] = level.TileMap[Empty]
+	}
+}
+
+func (level *Level) findTile(r rune) (Pos, bool) {
+	for y, row := range level.Level {
+		for x, t := range row {
+			if t.Rune == r {
+				return Pos{x, y}, true
+			}
+		}
+	}
+	return Pos{}, false
+}
+
+// addGeneratedLevels hangs params.Depth generated levels off the first downstairs in the hand made
+// levels that doesn't lead anywhere yet
+func (g *Game) addGeneratedLevels(params DungeonParams) error {
+	if params.Depth <= 0 {
+		return nil
+	}
+
+	var above *Level
+	var exit Pos
+	for _, name := range g.levelNames() {
+		level := g.Levels[name]
+		for y, row := range level.Level {
+			for x, t := range row {
+				pos := Pos{x, y}
+				if above == nil && t.Rune == DownStairs && level.StairMap[pos] == nil {
+					above = level
+					exit = pos
+				}
+			}
+		}
+	}
+	if above == nil {
+		return fmt.Errorf("%d generated level(s) asked for but none of the levels has a downstairs that doesn't lead anywhere yet", params.Depth)
+	}
+
+	for depth := 1; depth <= params.Depth; depth++ {
+		name := fmt.Sprintf("dungeon%d", depth)
+		level, err := GenerateLevel(name, params, depth, above.Player)
+		if err != nil {
+			return err
+		}
+
+		upstairs, _ := level.findTile(UpStairs)
+		above.StairMap[exit] = &LevelPos{level, upstairs}
+		level.StairMap[upstairs] = &LevelPos{above, exit}
+		g.Levels[name] = level
+
+		if depth == params.Depth {
+			break
+		}
+		downstairs, found := level.findTile(DownStairs)
+		if !found {
+			return fmt.Errorf("%s has no downstairs for the %d level(s) below it", name, params.Depth-depth)
+		}
+		above = level
+		exit = downstairs
+	}
+	return nil
+}
+
+func sign(i int) int {
+	switch {
+	case i > 0:
+		return 1
+	case i < 0:
+		return -1
+	}
+	return 0
+}
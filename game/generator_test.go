@@ -0,0 +1,113 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateLevelBadParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*DungeonParams)
+	}{
+		{"negative height", func(p *DungeonParams) { p.Height = -1 }},
+		{"zero width", func(p *DungeonParams) { p.Width = 0 }},
+		{"no rooms", func(p *DungeonParams) { p.Rooms = 0 }},
+		{"negative monsters", func(p *DungeonParams) { p.Monsters = -3 }},
+		{"too small for a room", func(p *DungeonParams) { p.Width, p.Height = 4, 4 }},
+	}
+	for _, tt := range tests {
+		params := DefaultDungeonParams()
+		params.Depth = 1
+		tt.change(&params)
+		if _, err := GenerateLevel("dungeon1", params, 1, &Player{}); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestGeneratedLevelsNeedFreeDownstairs(t *testing.T) {
+	g := newTestGame(t, 0)
+	// the generated levels took the only free downstairs, there's nowhere for more
+	if err := g.addGeneratedLevels(DungeonParams{Seed: 2, Depth: 1, Width: 40, Height: 30, Rooms: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.addGeneratedLevels(DungeonParams{Seed: 3, Depth: 1, Width: 40, Height: 30, Rooms: 10}); err == nil {
+		t.Error("expected an error when there's no free downstairs")
+	}
+}
+
+func TestSameSeedSameDungeon(t *testing.T) {
+	params := DefaultDungeonParams()
+	params.Depth = 3
+	g1 := newTestGame(t, 0)
+	if err := g1.addGeneratedLevels(params); err != nil {
+		t.Fatal(err)
+	}
+	g2 := newTestGame(t, 0)
+	if err := g2.addGeneratedLevels(params); err != nil {
+		t.Fatal(err)
+	}
+
+	for depth := 1; depth <= params.Depth; depth++ {
+		name := fmt.Sprintf("dungeon%d", depth)
+		a, b := g1.Levels[name], g2.Levels[name]
+		if a == nil || b == nil {
+			t.Fatalf("%s is missing", name)
+		}
+		if !reflect.DeepEqual(a.Level, b.Level) {
+			t.Errorf("%s: the tiles are different", name)
+		}
+		if len(a.Monsters) != len(b.Monsters) {
+			t.Errorf("%s: %d monsters and %d", name, len(a.Monsters), len(b.Monsters))
+		}
+		for pos, m := range a.Monsters {
+			if other, exists := b.Monsters[pos]; !exists || other.Name != m.Name {
+				t.Errorf("%s: %s at %v isn't in the other dungeon", name, m.Name, pos)
+			}
+		}
+		if len(a.Items) != len(b.Items) {
+			t.Errorf("%s: items in %d places and %d", name, len(a.Items), len(b.Items))
+		}
+		if a.R.Int63() != b.R.Int63() {
+			t.Errorf("%s: the random numbers are different", name)
+		}
+	}
+}
+
+func TestLevelSeedsDontOverlap(t *testing.T) {
+	params := DefaultDungeonParams()
+	params.Depth = 2
+	a, err := GenerateLevel("a", params, 2, &Player{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.Seed++
+	b, err := GenerateLevel("b", params, 1, &Player{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(a.Level, b.Level) {
+		t.Error("seed 1 at depth 2 has the same tiles as seed 2 at depth 1")
+	}
+	if a.src.seed == b.src.seed {
+		t.Error("seed 1 at depth 2 has the same random numbers as seed 2 at depth 1")
+	}
+}
+
+func TestGeneratedLevelsAlwaysGoDown(t *testing.T) {
+	// with a single room the downstairs sometimes lands where the upstairs went first
+	for seed := int64(0); seed < 200; seed++ {
+		params := DungeonParams{Seed: seed, Depth: 2, Width: 16, Height: 14, Rooms: 1}
+		level, err := GenerateLevel("dungeon1", params, 1, &Player{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, up := level.findTile(UpStairs)
+		_, down := level.findTile(DownStairs)
+		if !up || !down {
+			t.Fatalf("seed %d: upstairs %v, downstairs %v", seed, up, down)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package game
+
+import "fmt"
+
+// Monsters hunting together. Everyone after the same spot walks down one distance map instead of
+// planning a path of its own, so a pack spreads out around the player rather than queueing up
+// behind whoever is in front, and a monster that spots the player tells the others close by
+
+const (
+	alertRange    = 8   // monsters of the same kind this close hear about it when one sees the player
+	surroundRange = 2   // this close to the spot a blocked monster steps round the others instead of waiting
+	fleeFactor    = 1.2 // how far out of its way a running monster goes to get past the player, see Invert
+)
+
+// huntMap is the map towards goal, everyone hunting goal in the same tick gets the same one. Monsters
+// move on so it's made as if they weren't there
+func (level *Level) huntMap(goal Pos) *DijkstraMap {
+	if dm, exists := level.huntMaps[goal]; exists {
+		return dm
+	}
+	if level.huntMaps == nil {
+		level.huntMaps = make(map[Pos]*DijkstraMap)
+	}
+	dm := level.DijkstraMap([]Goal{{goal, 0}}, canPatrol)
+	level.huntMaps[goal] = dm
+	return dm
+}
+
+// fleeMap is huntMap turned around for running away from from, shared the same way
+func (level *Level) fleeMap(from Pos) *DijkstraMap {
+	if dm, exists := level.fleeMaps[from]; exists {
+		return dm
+	}
+	if level.fleeMaps == nil {
+		level.fleeMaps = make(map[Pos]*DijkstraMap)
+	}
+	dm := level.huntMap(from).Invert(level, canPatrol, fleeFactor)
+	level.fleeMaps[from] = dm
+	return dm
+}
+
+// huntStep moves m one step down the shared map towards goal. A monster with a packmate in its way
+// waits for it to move on unless it's close enough to go round, then it picks the side with the
+// fewest packmates so they end up all around the goal. It returns false when goal can't be reached
+// over the map, waiting counts as a way there
+func (m *Monster) huntStep(level *Level, goal Pos) bool {
+	dm := level.huntMap(goal)
+	here := dm.At(m.Pos)
+	if here == Unreachable {
+		return false
+	}
+
+	best := m.Pos
+	bestDist, bestCrowd := here, 0
+	blocked := false
+	var sidesteps []Pos
+	for _, n := range getNeighbours(level, m.Pos, canPatrol) {
+		d := dm.At(n)
+		if d > here {
+			continue
+		}
+		if _, taken := level.Monsters[n]; taken {
+			if d < here {
+				blocked = true
+			}
+			continue
+		}
+		if d == here {
+			sidesteps = append(sidesteps, n)
+			continue
+		}
+		crowd := level.packmatesNear(m, n)
+		if best == m.Pos || d < bestDist || d == bestDist && crowd < bestCrowd {
+			best, bestDist, bestCrowd = n, d, crowd
+		}
+	}
+
+	if best == m.Pos && blocked && chebyshev(m.Pos, goal) <= surroundRange {
+		for _, n := range sidesteps {
+			crowd := level.packmatesNear(m, n)
+			if best == m.Pos || crowd < bestCrowd {
+				best, bestCrowd = n, crowd
+			}
+		}
+	}
+	if best == m.Pos {
+		return blocked
+	}
+	m.Move(best, level)
+	return true
+}
+
+// packmatesNear counts the monsters of the same kind as m next to pos, not counting m
+func (level *Level) packmatesNear(m *Monster, pos Pos) int {
+	count := 0
+	for _, d := range directions {
+		other, exists := level.Monsters[Pos{pos.X + d.X, pos.Y + d.Y}]
+		if exists && other != m && other.Rune == m.Rune {
+			count++
+		}
+	}
+	return count
+}
+
+// alertPack tells the monsters of the same kind within alertRange where m saw the player, they
+// hunt it as if they'd seen it themselves and so share the same distance map as m
+func (m *Monster) alertPack(level *Level) {
+	woken := 0
+	for _, other := range level.monstersInOrder() {
+		if other == m || other.Rune != m.Rune || chebyshev(other.Pos, m.Pos) > alertRange {
+			continue
+		}
+		if other.Memory == 0 {
+			woken++
+		}
+		other.LastSeen = m.LastSeen
+		other.Memory = monsterMemory
+	}
+	if woken > 0 {
+		level.Events.Add(Event{Category: CombatEvent, Severity: Warning, Source: m.Name, Text: fmt.Sprintf("%s calls out to the others", m.Name)})
+	}
+}
@@ -0,0 +1,54 @@
+package game
+
+import "testing"
+
+func TestAlertPack(t *testing.T) {
+	level, mk := aiLevel(t, 1,
+		"################",
+		"#P..A......B.C.#",
+		"#....S.........#",
+		"################",
+	)
+	a := aiMonster(level, mk['A'], "hunt")
+	b := aiMonster(level, mk['B'], "hunt")
+	c := aiMonster(level, mk['C'], "hunt")
+	spider := aiMonster(level, mk['S'], "hunt")
+	spider.Name, spider.Rune = "Spider", 's'
+
+	act(a, level)
+	if a.State != Hunt {
+		t.Fatalf("A is in state %d, it should have seen the player", a.State)
+	}
+	if b.LastSeen != mk['P'] || b.Memory != monsterMemory {
+		t.Errorf("B within %d of A remembers %v for %d, want %v for %d", alertRange, b.LastSeen, b.Memory, mk['P'], monsterMemory)
+	}
+	for _, other := range []*Monster{c, spider} {
+		if other.Memory != 0 || other.LastSeen != (Pos{}) {
+			t.Errorf("%s on %v was alerted too", other.Name, other.Pos)
+		}
+	}
+	calls := 0
+	for _, e := range level.Events.Events {
+		if e.Text == "Rat calls out to the others" {
+			calls++
+		}
+	}
+	if calls != 1 {
+		t.Errorf("A called out %d times, want once", calls)
+	}
+
+	// B never saw the player but goes after it all the same
+	act(b, level)
+	if b.seesPlayer || b.State != Hunt || b.Pos.X >= mk['B'].X {
+		t.Errorf("B sees the player %v, state %d, moved to %v", b.seesPlayer, b.State, b.Pos)
+	}
+
+	// everyone's already awake so there's nobody to call out to
+	events := len(level.Events.Events)
+	act(a, level)
+	for _, e := range level.Events.Events[events:] {
+		if e.Text == "Rat calls out to the others" {
+			t.Error("A called out again with the pack already hunting")
+		}
+	}
+}
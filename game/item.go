@@ -0,0 +1,134 @@
+package game
+
+import "fmt"
+
+type ItemKind int
+
+const (
+	Weapon ItemKind = iota
+	Armor
+	Potion
+)
+
+// MaxCarryWeight is how much the player can have in their inventory
+const MaxCarryWeight = 20
+
+type Item struct {
+	Entity
+	Kind   ItemKind
+	Weight int
+	Power  int // extra damage for weapons, damage soaked for armor, hitpoints for potions
+}
+
+func NewSword(p Pos) *Item {
+	return &Item{Entity{p, Sword, "Sword"}, Weapon, 6, 2}
+}
+
+func NewLeatherArmor(p Pos) *Item {
+	return &Item{Entity{p, LeatherArmor, "Leather Armor"}, Armor, 8, 1}
+}
+
+func NewHealthPotion(p Pos) *Item {
+	return &Item{Entity{p, HealthPotion, "Health Potion"}, Potion, 1, 10}
+}
+
+func (level *Level) addItem(item *Item) {
+	level.Items[item.Pos] = append(level.Items[item.Pos], item)
+}
+
+func (p *Player) carryWeight() int {
+	weight := 0
+	for _, item := range p.Inventory {
+		weight += item.Weight
+	}
+	return weight
+}
+
+// pickUp takes the top item from the floor under the player
+func (p *Player) pickUp(level *Level) {
+	items := level.Items[p.Pos]
+	if len(items) == 0 || p.AP < 1 {
+		return
+	}
+
+	item := items[len(items)-1]
+	if p.carryWeight()+item.Weight > MaxCarryWeight {
+		level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s is too heavy to carry", item.Name)})
+		return
+	}
+
+	if len(items) == 1 {
+		delete(level.Items, p.Pos)
+	} else {
+		level.Items[p.Pos] = items[:len(items)-1]
+	}
+	p.Inventory = append(p.Inventory, item)
+	p.AP--
+	level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s picked up %s", p.Name, item.Name)})
+}
+
+func (p *Player) drop(level *Level, index int) {
+	if index < 0 || index >= len(p.Inventory) || p.AP < 1 {
+		return
+	}
+
+	item := p.Inventory[index]
+	if p.Weapon == item {
+		p.Weapon = nil
+	}
+	if p.Armor == item {
+		p.Armor = nil
+	}
+	p.Inventory = append(p.Inventory[:index], p.Inventory[index+1:]...)
+	item.Pos = p.Pos
+	level.addItem(item)
+	p.AP--
+	level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s dropped %s", p.Name, item.Name)})
+}
+
+// useItem equips weapons and armor, or takes them off if they are already worn, and drinks potions
+func (p *Player) useItem(level *Level, index int) {
+	if index < 0 || index >= len(p.Inventory) || p.AP < 1 {
+		return
+	}
+
+	item := p.Inventory[index]
+	switch item.Kind {
+	case Weapon:
+		if p.Weapon == item {
+			p.Weapon = nil
+			level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s put away %s", p.Name, item.Name)})
+		} else {
+			p.Weapon = item
+			level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s wielded %s", p.Name, item.Name)})
+		}
+	case Armor:
+		if p.Armor == item {
+			p.Armor = nil
+			level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s took off %s", p.Name, item.Name)})
+		} else {
+			p.Armor = item
+			level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s put on %s", p.Name, item.Name)})
+		}
+	case Potion:
+		p.Hitpoints += item.Power
+		p.Inventory = append(p.Inventory[:index], p.Inventory[index+1:]...)
+		level.Events.Add(Event{Category: ItemEvent, Source: p.Name, Text: fmt.Sprintf("%s drank %s and healed %d", p.Name, item.Name, item.Power)})
+	}
+	p.AP--
+}
+
+func (p *Player) GetInventoryStrings() []string {
+	var inventory []string
+
+	inventory = append(inventory, fmt.Sprintf("Inventory %d/%d", p.carryWeight(), MaxCarryWeight))
+	for i, item := range p.Inventory {
+		line := fmt.Sprintf("%d: %s", i+1, item.Name)
+		if item == p.Weapon || item == p.Armor {
+			line += " (E)"
+		}
+		inventory = append(inventory, line)
+	}
+
+	return inventory
+}
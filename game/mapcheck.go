@@ -0,0 +1,270 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// MapProblem is one thing CheckMaps found. Warnings are worth knowing about but the game still loads
+type MapProblem struct {
+	MapError
+	Warning bool
+}
+
+func (p MapProblem) String() string {
+	if p.Warning {
+		return "warning: " + p.MapError.Error()
+	}
+	return p.MapError.Error()
+}
+
+// checkedStairs is a stairs tile something leads away from, and where that was written down
+type checkedStairs struct {
+	level *Level
+	pos   Pos
+	path  string
+	line  int
+}
+
+// passable is canWalk for map checking, monsters don't count and doors can be opened
+func passable(level *Level, pos Pos) bool {
+	switch level.TileAtPos(pos).Type {
+	case "Wall", "Empty":
+		return false
+	}
+	return true
+}
+
+func isStairs(level *Level, pos Pos) bool {
+	r := level.TileAtPos(pos).Rune
+	return r == UpStairs || r == DownStairs
+}
+
+// CheckMaps goes through every map in fsys and the loaded packs, and the world.txt in fsys, the way the
+// game would load them but keeps going after a problem so everything can be fixed in one go
+func CheckMaps(fsys fs.FS) []MapProblem {
+	var problems []MapProblem
+	report := func(path string, line int, warning bool, format string, a ...interface{}) {
+		problems = append(problems, MapProblem{MapError{path, line, fmt.Errorf(format, a...)}, warning})
+	}
+	reportErr := func(path string, err error) {
+		var mapErr *MapError
+		if errors.As(err, &mapErr) {
+			problems = append(problems, MapProblem{*mapErr, false})
+		} else {
+			report(path, 0, false, "%v", err)
+		}
+	}
+
+	files, err := mapFiles(fsys)
+	if err != nil || len(files) == 0 {
+		report("maps", 0, false, "no .map files")
+		return problems
+	}
+
+	levels := make(map[string]*Level)
+	paths := make(map[string]string)
+	starts := make(map[*Level][]Pos)
+	var stairs []checkedStairs
+	arrivals := make(map[*Level][]Pos)
+	player := &Player{}
+
+	for _, file := range files {
+		path := file.path
+		mf, err := parseMapFile(file.fsys, path)
+		if err != nil {
+			reportErr(path, err)
+			continue
+		}
+
+		widest := 0
+		for _, row := range mf.rows {
+			if len(row) > widest {
+				widest = len(row)
+			}
+		}
+		narrow := 0
+		firstNarrow := 0
+		var playerStarts []Pos
+		for y, row := range mf.rows {
+			if len(row) < widest {
+				if narrow == 0 {
+					firstNarrow = mf.firstRow + y
+				}
+				narrow++
+			}
+			for x, r := range row {
+				e, exists := mf.entry(r)
+				if !exists {
+					problems = append(problems, MapProblem{MapError{path, mf.firstRow + y, &RuneError{r, Pos{x, y}}}, false})
+					// carry on as if it was empty space so the rest of the file still gets checked
+					mf.legend[r] = legendEntry{tile: Empty}
+					continue
+				}
+				if e.player {
+					playerStarts = append(playerStarts, Pos{x, y})
+				}
+			}
+		}
+		if narrow > 0 {
+			report(path, firstNarrow, true, "%d row(s) are narrower than the widest one (%d), they get filled up with empty space that can't be walked on or seen through", narrow, widest)
+		}
+
+		triggers := mf.triggers
+		mf.triggers = nil
+		level, err := mf.buildLevel(player, 0)
+		if err != nil {
+			reportErr(path, err)
+			continue
+		}
+		for _, t := range triggers {
+			pos, err := level.markerPos(t.at)
+			if err != nil {
+				report(path, t.lineNum, false, "%v", err)
+			} else if !passable(level, pos) {
+				report(path, t.lineNum, true, "trigger at %d,%d is on a tile nobody can stand on", pos.X, pos.Y)
+			}
+		}
+
+		if other, exists := paths[level.Name]; exists {
+			report(path, 0, false, "there is already a level called %s in %s", level.Name, other)
+			continue
+		}
+		levels[level.Name] = level
+		paths[level.Name] = path
+		starts[level] = playerStarts
+	}
+
+	// stairs from the [stairs] sections go both ways
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		level := levels[name]
+		for _, link := range level.stairLinks {
+			from, err := level.markerPos(link.from)
+			if err != nil {
+				report(link.path, link.lineNum, false, "%v", err)
+				continue
+			}
+			other := levels[link.level]
+			if other == nil {
+				report(link.path, link.lineNum, false, "no level called %s", link.level)
+				continue
+			}
+			to, err := other.markerPos(link.to)
+			if err != nil {
+				report(link.path, link.lineNum, false, "%v", err)
+				continue
+			}
+			if !isStairs(level, from) {
+				report(link.path, link.lineNum, false, "%d,%d in %s isn't a stairs tile", from.X, from.Y, name)
+			}
+			if !isStairs(other, to) {
+				report(link.path, link.lineNum, false, "%d,%d in %s isn't a stairs tile", to.X, to.Y, other.Name)
+			}
+			stairs = append(stairs, checkedStairs{level, from, link.path, link.lineNum}, checkedStairs{other, to, link.path, link.lineNum})
+			arrivals[other] = append(arrivals[other], to)
+			arrivals[level] = append(arrivals[level], from)
+		}
+	}
+
+	worldPath := "maps/world.txt"
+	started := false
+	lines, err := readLines(fsys, worldPath)
+	if err != nil {
+		report(worldPath, 0, false, "%v", err)
+	}
+	for i, line := range lines {
+		lineNum := i + 1
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !started {
+			started = true
+			name := strings.TrimSpace(strings.Split(line, ",")[0])
+			if levels[name] == nil {
+				report(worldPath, lineNum, false, "no level called %s to start on", name)
+			} else if len(starts[levels[name]]) == 0 {
+				report(worldPath, lineNum, false, "the first level %s has no player start", name)
+			}
+			continue
+		}
+
+		link, err := parseWorldLine(line)
+		if err != nil {
+			report(worldPath, lineNum, false, "%v", err)
+			continue
+		}
+		from, to := levels[link.from], levels[link.to]
+		switch {
+		case from == nil:
+			report(worldPath, lineNum, false, "no level called %s", link.from)
+		case to == nil:
+			report(worldPath, lineNum, false, "no level called %s", link.to)
+		case !inRange(from, link.fromPos):
+			report(worldPath, lineNum, false, "%d,%d is outside of %s", link.fromPos.X, link.fromPos.Y, from.Name)
+		case !inRange(to, link.toPos):
+			report(worldPath, lineNum, false, "%d,%d is outside of %s", link.toPos.X, link.toPos.Y, to.Name)
+		case !isStairs(from, link.fromPos):
+			report(worldPath, lineNum, false, "%d,%d in %s isn't a stairs tile", link.fromPos.X, link.fromPos.Y, from.Name)
+		case !passable(to, link.toPos):
+			report(worldPath, lineNum, false, "%d,%d in %s can't be stood on", link.toPos.X, link.toPos.Y, to.Name)
+		default:
+			stairs = append(stairs, checkedStairs{from, link.fromPos, worldPath, lineNum})
+			arrivals[to] = append(arrivals[to], link.toPos)
+		}
+	}
+	if err == nil && !started {
+		report(worldPath, 0, false, "no level to start on")
+	}
+
+	total := 0
+	for _, name := range names {
+		level := levels[name]
+		total += len(starts[level])
+		if len(starts[level]) > 1 {
+			report(paths[name], 0, false, "%d player starts, there should only be one", len(starts[level]))
+		}
+		if len(starts[level]) == 0 && len(arrivals[level]) == 0 {
+			report(paths[name], 0, false, "no player start and no stairs lead here, %s can't be reached", name)
+		}
+	}
+	if total == 0 {
+		report("maps", 0, false, "none of the maps has a player start")
+	} else if total > 1 {
+		report("maps", 0, false, "%d player starts across the maps, there should only be one", total)
+	}
+
+	// every stairs tile has to be reachable from the other places the player can turn up on its level,
+	// stairs that are the only way in are fine on their own
+	for _, s := range stairs {
+		reachable := true
+		for _, entry := range append(append([]Pos{}, starts[s.level]...), arrivals[s.level]...) {
+			if entry == s.pos {
+				continue
+			}
+			reachable = false
+			if _, _, found := s.level.findPath(entry, s.pos, passable); found {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			report(s.path, s.line, false, "stairs at %d,%d in %s can't be reached", s.pos.X, s.pos.Y, s.level.Name)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems
+}
@@ -0,0 +1,51 @@
+package game
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckMapsNarrowRows(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/test.map":  {Data: []byte("#####\n#@..#\n#..\n#####\n")},
+		"maps/world.txt": {Data: []byte("test\n")},
+	}
+	problems := CheckMaps(fsys)
+	if len(problems) != 1 || !problems[0].Warning || problems[0].Line != 3 || !strings.Contains(problems[0].Error(), "empty space") {
+		t.Fatalf("expected a warning about row 3, got %v", problems)
+	}
+
+	// and the game really does fill them up that way
+	levels, err := loadLevels(fsys, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := levels["test"]
+	for x := 3; x < 5; x++ {
+		if tile := level.Level[2][x]; tile.Type != "Empty" {
+			t.Errorf("%d,2 is %+v, want empty space", x, tile)
+		}
+	}
+}
+
+func TestCheckMapsProblems(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/a.map":     {Data: []byte("#####\n#@.d#\n#####\n")},
+		"maps/b.map":     {Data: []byte("#####\n#u#d#\n#####\n#?###\n")},
+		"maps/world.txt": {Data: []byte("a\na,3,1,b,1,1\nb,1,1,a,3,1\nb,3,1,c,1,1\nb,3,1\n")},
+	}
+	want := []string{
+		"maps/b.map:4: unknown rune '?' at column 2",
+		"maps/world.txt:4: no level called c",
+		"maps/world.txt:5: expected level,x,y,level,x,y, got 3 field(s)",
+	}
+
+	var got []string
+	for _, p := range CheckMaps(fsys) {
+		got = append(got, p.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
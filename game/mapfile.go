@@ -0,0 +1,523 @@
+package game
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Map files come in two formats. The old one is just the grid, every rune means what the default
+// legend says. The newer one starts with a "rpgmap 2" line followed by a header and sections:
+//
+//	rpgmap 2
+//	name = level2
+//	description = A huge open cavern
+//	light = 0
+//	music = cave
+//	[legend]
+//	; the default legend is still there, these add to it or replace parts of it
+//	R = monster Rat on water
+//	) = item sword
+//	[map]
+//	#####...
+//	[stairs]
+//	u -> level1 d
+//	[triggers]
+//	10,5 You hear something skittering in the dark
+//
+// Stairs and triggers point at a position either as x,y or as a character that shows up exactly once
+// in the map, stairs always work both ways. Lines starting with ; are comments outside of [map]
+
+const mapHeader = "rpgmap 2"
+
+// MapError is a problem at a line of a map, world or pack file, Line is 0 when it's about the whole file
+type MapError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *MapError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+}
+
+func (e *MapError) Unwrap() error {
+	return e.Err
+}
+
+// RuneError is a character in a map that isn't in the legend and isn't a monster either,
+// it comes wrapped in a MapError that has the line
+type RuneError struct {
+	Rune rune
+	Pos
+}
+
+func (e *RuneError) Error() string {
+	return fmt.Sprintf("unknown rune '%s' at column %d", string(e.Rune), e.X+1)
+}
+
+// Trigger logs its text the first time the player steps on it
+type Trigger struct {
+	Pos
+	Text  string
+	Fired bool
+}
+
+// legendEntry is what a character in a map turns into, a tile plus optionally something on top
+type legendEntry struct {
+	tile    rune
+	monster *MonsterType
+	item    func(Pos) *Item
+	player  bool
+}
+
+type stairLink struct {
+	path    string
+	from    string // x,y or a character of this map
+	level   string
+	to      string // x,y or a character of the other map
+	lineNum int
+}
+
+type triggerDef struct {
+	at      string
+	text    string
+	lineNum int
+}
+
+type mapFile struct {
+	path        string
+	name        string
+	description string
+	music       string
+	light       int
+	legend      map[rune]legendEntry
+	rows        [][]rune // runes not bytes so a multi byte character is still one tile
+	firstRow    int      // line number of the first map row, for error messages
+	stairs      []stairLink
+	triggers    []triggerDef
+}
+
+var legendTiles = map[string]rune{
+	"wall":       StoneWall,
+	"floor":      DirtFloor,
+	"door":       ClosedDoor,
+	"open door":  OpenDoor,
+	"water":      Water,
+	"torch":      Torch,
+	"upstairs":   UpStairs,
+	"downstairs": DownStairs,
+	"empty":      Empty,
+}
+
+var legendItems = map[string]func(Pos) *Item{
+	"sword":         NewSword,
+	"leather armor": NewLeatherArmor,
+	"health potion": NewHealthPotion,
+}
+
+// defaultLegend is what the old format always used, monsters come from MonsterTypes on top of this
+func defaultLegend() map[rune]legendEntry {
+	legend := map[rune]legendEntry{
+		'@': {tile: DirtFloor, player: true},
+		')': {tile: DirtFloor, item: NewSword},
+		'[': {tile: DirtFloor, item: NewLeatherArmor},
+		'!': {tile: DirtFloor, item: NewHealthPotion},
+	}
+	for _, r := range []rune{' ', '\t', '\r'} {
+		legend[r] = legendEntry{tile: Empty}
+	}
+	for _, r := range legendTiles {
+		if r != Empty {
+			legend[r] = legendEntry{tile: r}
+		}
+	}
+	for r := range PackTiles {
+		legend[r] = legendEntry{tile: r}
+	}
+	return legend
+}
+
+// tileNamed looks up a tile for the legend by name, pack tiles go by their Name
+func tileNamed(name string) (rune, bool) {
+	if r, exists := legendTiles[strings.ToLower(name)]; exists {
+		return r, true
+	}
+	for r, t := range PackTiles {
+		if strings.EqualFold(t.Name, name) {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// entry looks r up in the legend, falling back to the monster with that rune
+func (mf *mapFile) entry(r rune) (legendEntry, bool) {
+	e, exists := mf.legend[r]
+	if exists {
+		return e, true
+	}
+	mt, exists := MonsterTypes[r]
+	if exists {
+		return legendEntry{tile: DirtFloor, monster: mt}, true
+	}
+	return legendEntry{}, false
+}
+
+// readLines reads a text file into lines without their line endings, a missing file is an *fs.PathError
+func readLines(fsys fs.FS, path string) ([]string, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+func parseMapFile(fsys fs.FS, path string) (*mapFile, error) {
+	lines, err := readLines(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+
+	mf := &mapFile{
+		path:     path,
+		name:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		legend:   defaultLegend(),
+		firstRow: 1,
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != mapHeader {
+		for _, line := range lines {
+			mf.rows = append(mf.rows, []rune(line))
+		}
+		return mf, nil
+	}
+
+	section := "header"
+	mf.firstRow = 0
+	for i, line := range lines[1:] {
+		lineNum := i + 2
+		switch strings.TrimSpace(line) {
+		case "[legend]", "[map]", "[stairs]", "[triggers]":
+			section = strings.Trim(strings.TrimSpace(line), "[]")
+			if section == "map" {
+				mf.firstRow = lineNum + 1
+			}
+			continue
+		}
+
+		if section == "map" {
+			mf.rows = append(mf.rows, []rune(line))
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' {
+			continue
+		}
+
+		var err error
+		switch section {
+		case "header":
+			err = mf.parseHeader(line)
+		case "legend":
+			err = mf.parseLegend(line)
+		case "stairs":
+			err = mf.parseStairs(line, lineNum)
+		case "triggers":
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) < 2 {
+				err = fmt.Errorf("trigger needs a position and some text")
+			} else {
+				mf.triggers = append(mf.triggers, triggerDef{fields[0], strings.TrimSpace(fields[1]), lineNum})
+			}
+		}
+		if err != nil {
+			return nil, &MapError{path, lineNum, err}
+		}
+	}
+
+	// blank lines between the grid and the next section aren't part of the map
+	for len(mf.rows) > 0 && strings.TrimSpace(string(mf.rows[len(mf.rows)-1])) == "" {
+		mf.rows = mf.rows[:len(mf.rows)-1]
+	}
+	if mf.firstRow == 0 {
+		return nil, &MapError{path, 0, fmt.Errorf("no [map] section")}
+	}
+	return mf, nil
+}
+
+func (mf *mapFile) parseHeader(line string) error {
+	key, value, found := cutString(line, "=")
+	if !found {
+		return fmt.Errorf("expected key = value, got %q", line)
+	}
+	switch key {
+	case "name":
+		mf.name = value
+	case "description":
+		mf.description = value
+	case "music":
+		mf.music = value
+	case "light":
+		light, err := strconv.Atoi(value)
+		if err != nil || light < 0 {
+			return fmt.Errorf("light %q should be a whole number 0 or more", value)
+		}
+		mf.light = light
+	default:
+		return fmt.Errorf("unknown header %q", key)
+	}
+	return nil
+}
+
+// parseLegend reads lines like "R = monster Rat on water", the "on" part picks the tile underneath
+func (mf *mapFile) parseLegend(line string) error {
+	r := []rune(line)[0]
+	value := strings.TrimSpace(line[len(string(r)):])
+	if !strings.HasPrefix(value, "=") {
+		return fmt.Errorf("expected %c = something, got %q", r, line)
+	}
+	fields := strings.Fields(value[1:])
+	if len(fields) == 0 {
+		return fmt.Errorf("nothing after %c =", r)
+	}
+
+	kind := fields[0]
+	name := fields[1:]
+	tileName := "floor"
+	for i, f := range name {
+		if f == "on" {
+			tileName = strings.Join(name[i+1:], " ")
+			name = name[:i]
+			break
+		}
+	}
+	if kind == "tile" {
+		tileName = strings.Join(name, " ")
+	}
+
+	tile, exists := tileNamed(tileName)
+	if !exists {
+		return fmt.Errorf("unknown tile %q", tileName)
+	}
+	e := legendEntry{tile: tile}
+
+	switch kind {
+	case "tile":
+	case "player":
+		e.player = true
+	case "item":
+		e.item = legendItems[strings.ToLower(strings.Join(name, " "))]
+		if e.item == nil {
+			return fmt.Errorf("unknown item %q", strings.Join(name, " "))
+		}
+	case "monster":
+		e.monster = findMonsterType(strings.Join(name, " "))
+		if e.monster == nil {
+			return fmt.Errorf("unknown monster %q", strings.Join(name, " "))
+		}
+	default:
+		return fmt.Errorf("unknown legend kind %q, expected tile, monster, item or player", kind)
+	}
+	mf.legend[r] = e
+	return nil
+}
+
+func (mf *mapFile) parseStairs(line string, lineNum int) error {
+	from, to, found := cutString(line, "->")
+	fields := strings.Fields(to)
+	if !found || from == "" || len(fields) != 2 {
+		return fmt.Errorf("expected stairs like \"u -> level1 d\", got %q", line)
+	}
+	mf.stairs = append(mf.stairs, stairLink{mf.path, from, fields[0], fields[1], lineNum})
+	return nil
+}
+
+// worldLink is a line of world.txt after the first: stairs at fromPos in from lead to toPos in to
+type worldLink struct {
+	from    string
+	fromPos Pos
+	to      string
+	toPos   Pos
+}
+
+func parseWorldLine(line string) (worldLink, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 6 {
+		return worldLink{}, fmt.Errorf("expected level,x,y,level,x,y, got %d field(s)", len(fields))
+	}
+	var coords [4]int
+	for i, f := range []string{fields[1], fields[2], fields[4], fields[5]} {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return worldLink{}, fmt.Errorf("%q isn't a number", strings.TrimSpace(f))
+		}
+		coords[i] = n
+	}
+	return worldLink{
+		from:    strings.TrimSpace(fields[0]),
+		fromPos: Pos{coords[0], coords[1]},
+		to:      strings.TrimSpace(fields[3]),
+		toPos:   Pos{coords[2], coords[3]},
+	}, nil
+}
+
+// findMonsterType matches a monster by name, or by rune for a single character
+func findMonsterType(name string) *MonsterType {
+	for _, mt := range monsterTypeList() {
+		if strings.EqualFold(mt.Name, name) {
+			return mt
+		}
+	}
+	if len([]rune(name)) == 1 {
+		return MonsterTypes[[]rune(name)[0]]
+	}
+	return nil
+}
+
+// cutString splits s around the first sep and trims both halves
+func cutString(s, sep string) (before, after string, found bool) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return strings.TrimSpace(s), "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(sep):]), true
+}
+
+// buildLevel turns a parsed map into a level. The stairs are left for linkStairs since they
+// can point at levels that aren't built yet
+func (mf *mapFile) buildLevel(player *Player, seed int64) (*Level, error) {
+	level := &Level{}
+	level.Name = mf.name
+	level.Description = mf.description
+	level.Music = mf.music
+	level.Ambient = mf.light
+	level.Debug = make(map[Pos]bool)
+	level.seedRandom(seed)
+	level.StairMap = make(map[Pos]*LevelPos)
+	level.Player = player
+	level.Monsters = make(map[Pos]*Monster)
+	level.Items = make(map[Pos][]*Item)
+	level.LoadTileMap()
+	level.markers = make(map[rune][]Pos)
+	level.stairLinks = mf.stairs
+
+	longestRow := 0
+	for _, row := range mf.rows {
+		if len(row) > longestRow {
+			longestRow = len(row)
+		}
+	}
+	// short rows are filled up with empty space so every row is as long as the longest
+	level.Level = make([][]Tile, len(mf.rows))
+	for i := range level.Level {
+		level.Level[i] = make([]Tile, longestRow)
+		for x := range level.Level[i] {
+			level.Level[i][x] = level.TileMap[Empty]
+		}
+	}
+
+	for y, line := range mf.rows {
+		for x, r := range line {
+			p := Pos{X: x, Y: y}
+			e, exists := mf.entry(r)
+			if !exists {
+				return nil, &MapError{mf.path, mf.firstRow + y, &RuneError{r, p}}
+			}
+			level.Level[y][x] = level.TileMap[e.tile]
+			level.markers[r] = append(level.markers[r], p)
+			switch {
+			case e.player:
+				level.Player.Pos = p
+			case e.item != nil:
+				level.addItem(e.item(p))
+			case e.monster != nil:
+				level.Monsters[p] = NewMonster(e.monster, p)
+			}
+		}
+	}
+
+	for _, t := range mf.triggers {
+		pos, err := level.markerPos(t.at)
+		if err != nil {
+			return nil, &MapError{mf.path, t.lineNum, err}
+		}
+		level.Triggers = append(level.Triggers, &Trigger{Pos: pos, Text: t.text})
+	}
+
+	return level, nil
+}
+
+// markerPos finds a position written as x,y or as a character that is in the map exactly once
+func (level *Level) markerPos(at string) (Pos, error) {
+	if x, y, found := cutString(at, ","); found {
+		px, errX := strconv.Atoi(x)
+		py, errY := strconv.Atoi(y)
+		if errX != nil || errY != nil {
+			return Pos{}, fmt.Errorf("bad position %q", at)
+		}
+		pos := Pos{px, py}
+		if !inRange(level, pos) {
+			return Pos{}, fmt.Errorf("position %s is outside of %s", at, level.Name)
+		}
+		return pos, nil
+	}
+
+	runes := []rune(at)
+	if len(runes) != 1 {
+		return Pos{}, fmt.Errorf("%q should be x,y or a single character", at)
+	}
+	positions := level.markers[runes[0]]
+	if len(positions) != 1 {
+		return Pos{}, fmt.Errorf("'%s' is in %s %d times, it has to be there exactly once", at, level.Name, len(positions))
+	}
+	return positions[0], nil
+}
+
+// linkStairs connects the stairs listed in the map files both ways
+func (g *Game) linkStairs() error {
+	for _, name := range g.levelNames() {
+		level := g.Levels[name]
+		for _, link := range level.stairLinks {
+			from, err := level.markerPos(link.from)
+			if err != nil {
+				return &MapError{link.path, link.lineNum, err}
+			}
+			other := g.Levels[link.level]
+			if other == nil {
+				return &MapError{link.path, link.lineNum, fmt.Errorf("no level called %s", link.level)}
+			}
+			to, err := other.markerPos(link.to)
+			if err != nil {
+				return &MapError{link.path, link.lineNum, err}
+			}
+			level.StairMap[from] = &LevelPos{other, to}
+			other.StairMap[to] = &LevelPos{level, from}
+		}
+	}
+
+	// the markers are only needed while loading
+	for _, level := range g.Levels {
+		level.markers = nil
+		level.stairLinks = nil
+	}
+	return nil
+}
+
+// fireTrigger logs the text of an unfired trigger at pos
+func (level *Level) fireTrigger(pos Pos) {
+	for _, t := range level.Triggers {
+		if t.Pos == pos && !t.Fired {
+			t.Fired = true
+			level.Events.Add(Event{Category: SystemEvent, Severity: Warning, Text: t.Text})
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package game
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestBuildLevelRowsAndRunes(t *testing.T) {
+	defer func(types map[rune]*MonsterType) { MonsterTypes = types }(MonsterTypes)
+	MonsterTypes = map[rune]*MonsterType{'é': {Rune: 'é', Name: "Eel", Hitpoints: 1, Speed: 1, Behavior: "idle"}}
+
+	fsys := fstest.MapFS{"maps/test.map": {Data: []byte("#####\n#é.@#\n#..\n#####\n")}}
+	mf, err := parseMapFile(fsys, "maps/test.map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	level, err := mf.buildLevel(&Player{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y, row := range level.Level {
+		if len(row) != 5 {
+			t.Errorf("row %d is %d tiles wide, want 5", y, len(row))
+		}
+	}
+	if m := level.Monsters[Pos{1, 1}]; m == nil || m.Name != "Eel" {
+		t.Errorf("the eel isn't at 1,1: %v", level.Monsters)
+	}
+	if level.Player.Pos != (Pos{3, 1}) {
+		t.Errorf("player at %v, want 3,1", level.Player.Pos)
+	}
+	if tile := level.Level[1][4]; tile.Rune != StoneWall {
+		t.Errorf("4,1 is %q, a multi byte rune shifted the row", tile.Rune)
+	}
+
+	// the short row is filled up with empty space nobody can walk on or see through
+	for _, pos := range []Pos{{3, 2}, {4, 2}} {
+		tile := level.TileAtPos(pos)
+		if tile.Type != "Empty" || canWalk(level, pos) || canSeeThrough(level, pos) {
+			t.Errorf("%v is %+v, want empty space", pos, *tile)
+		}
+	}
+}
@@ -2,92 +2,82 @@ package game
 
 import (
 	"fmt"
-	"math"
 )
 
 type Monster struct {
 	Character
+	Behavior     string
+	MaxHitpoints int
+	State        AIState
+	LastSeen     Pos // where it last saw the player, only means something while Memory is above 0
+	Memory       int // actions left before it stops looking for the player
+	Home         Pos // patrols go between Home and Waypoint
+	Waypoint     Pos
+	seesPlayer   bool
 }
 
-func NewRat(p Pos) *Monster {
-	return &Monster{Character{Entity{p, 'R', "Rat"}, "Monster", 5, 1, 1.5, 3, 0.0, true}}
-}
-
-func NewSpider(p Pos) *Monster {
-	return &Monster{Character{Entity{p, 'S', "Spider"}, "Monster", 7, 0, .25, 5, 0.0, true}}
+func NewMonster(mt *MonsterType, p Pos) *Monster {
+	return &Monster{
+		Character: Character{
+			Entity: Entity{
+				Pos:  p,
+				Rune: mt.Rune,
+				Name: mt.Name,
+			},
+			Type:       "Monster",
+			Hitpoints:  mt.Hitpoints,
+			Strength:   mt.Strength,
+			Defense:    mt.Defense,
+			Accuracy:   mt.Accuracy,
+			Damage:     mt.Damage,
+			Speed:      mt.Speed,
+			SightRange: mt.SightRange,
+			Alive:      true,
+		},
+		Behavior:     mt.Behavior,
+		MaxHitpoints: mt.Hitpoints,
+		Home:         p,
+		Waypoint:     p,
+	}
 }
 
+// Update makes the monster take a single action, the Scheduler calls it whenever the monster has enough AP
 func (m *Monster) Update(level *Level) {
-	p := level.Player
-	path, _, found := level.astar(m.Pos, p.Pos)
-	moveIndex := 1
-
-	if m.Hitpoints < 0 {
-		m.Dead(level)
+	if !m.Alive {
+		return
 	}
 
-	if found && p.Alive {
-		m.AP += m.Speed
-		apInt := int(m.AP)
-		for i := 0; i < apInt; i++ {
-			if moveIndex < len(path) {
-				if m.Move(path[moveIndex], level) {
-					moveIndex++
-				}
-			}
-		}
+	if behavior, exists := monsterBehaviors[m.Behavior]; exists {
+		behavior.Act(m, level)
 	}
 }
 
 func (m *Monster) Move(to Pos, level *Level) bool {
 	moved := false
 	tile := *level.TileAtPos(to)
-	if m.Hitpoints > 0 && m.AP >= float64(tile.Cost) {
+	if m.Hitpoints > 0 && m.AP >= ActionThreshold {
 		_, exists := level.Monsters[to]
 		if !exists && to != level.Player.Pos {
 			delete(level.Monsters, m.Pos)
 			level.Monsters[to] = m
 			m.Pos = to
-			fmt.Println("moved!")
 			m.AP -= float64(tile.Cost)
 			moved = true
 		} else if to == level.Player.Pos {
-			events := Attack(&m.Character, &level.Player.Character)
-			level.AddEvents(events...)
+			level.attack(&m.Character, &level.Player.Character)
 		}
 	}
 
 	return moved
 }
 
-// TODO: consider combining with lineOfSight taking in an character or entity
 func (m *Monster) isPlayerInRange(level *Level) bool {
-	pos := m.Pos
-	dist := m.SightRange
-	player := level.Player.Pos
-
-	for y := pos.Y - dist; y <= pos.Y+dist; y++ {
-		for x := pos.X - dist; x <= pos.X+dist; x++ {
-			xDelta := pos.X - x
-			yDelta := pos.Y - y
-			d := math.Sqrt(float64(xDelta*xDelta + yDelta*yDelta))
-			if d <= float64(dist) {
-				line := level.bresenham(pos, Pos{x, y})
-				for _, p := range line {
-					level.Debug[pos] = true
-					if p == player {
-						fmt.Println("target found")
-						return true
-					}
-				}
-			}
-		}
-	}
-	return false
+	return level.CanSee(&m.Character, level.Player.Pos)
 }
 
 func (m *Monster) Dead(level *Level) {
+	m.Alive = false
 	level.TileAtPos(m.Pos).BloodStained = true
-	level.AddEvents(fmt.Sprintf("%s died", m.Name))
+	level.Events.Add(Event{Category: DeathEvent, Severity: Warning, Target: m.Name, Text: fmt.Sprintf("%s died", m.Name)})
 	delete(level.Monsters, m.Pos)
 }
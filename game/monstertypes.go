@@ -0,0 +1,182 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MonsterType is one line of the monster data file, every monster in a level is made from one
+type MonsterType struct {
+	Rune       rune
+	Name       string
+	Hitpoints  int
+	Strength   int
+	Defense    int
+	Accuracy   int
+	Damage     Dice
+	Speed      float64
+	SightRange int
+	Behavior   string
+	SpriteX    int // position in the ui2d atlas, -1 when the atlas index should be used
+	SpriteY    int
+}
+
+// MonsterTypes is filled by LoadMonsterTypes and LoadPacks and looked up by loadLevels for any rune it doesn't know
+var MonsterTypes = make(map[rune]*MonsterType)
+
+// MonsterFile is the file in Content that LoadMonsterTypes last loaded, replays write it down
+var MonsterFile string
+
+// mapRunes are already taken by loadLevels so monsters can't use them
+var mapRunes = map[rune]bool{
+	PlayerTile: true, StoneWall: true, DirtFloor: true, ClosedDoor: true, OpenDoor: true, Water: true, Torch: true,
+	UpStairs: true, DownStairs: true, Sword: true, LeatherArmor: true, HealthPotion: true, ' ': true,
+}
+
+// MonsterFileError lists everything wrong with a monster file so it can all be fixed in one go
+type MonsterFileError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *MonsterFileError) Error() string {
+	return fmt.Sprintf("%s has %d problem(s):\n\t%s", e.Path, len(e.Problems), strings.Join(e.Problems, "\n\t"))
+}
+
+// LoadMonsterTypes reads monster definitions from a csv file in Content and replaces MonsterTypes
+// with them. Nothing is replaced if any line is bad. Packs add their monsters on top so load them after this
+func LoadMonsterTypes(path string) error {
+	types, err := parseMonsterTypes(Content, path)
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("%s: no monsters defined", path)
+	}
+
+	MonsterTypes = types
+	MonsterFile = path
+	return nil
+}
+
+func parseMonsterTypes(fsys fs.FS, path string) (map[rune]*MonsterType, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	types := make(map[rune]*MonsterType)
+	fileErr := &MonsterFileError{Path: path}
+	problem := func(line int, format string, args ...interface{}) {
+		fileErr.Problems = append(fileErr.Problems, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+	}
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		// # starts a comment unless it's being used as a rune, which is a mistake we want to report
+		if text == "" || strings.HasPrefix(text, "#") && !strings.HasPrefix(text, "#,") {
+			continue
+		}
+
+		row := strings.Split(text, ",")
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+
+		if len(row) != 12 {
+			problem(line, "expected 12 fields (rune, name, hitpoints, strength, defense, accuracy, damage, speed, sight range, behavior, sprite x, sprite y) but found %d", len(row))
+			continue
+		}
+
+		runes := []rune(row[0])
+		if len(runes) != 1 {
+			problem(line, "rune %q has to be a single character", row[0])
+			continue
+		}
+		mt := &MonsterType{Rune: runes[0], Name: row[1], Behavior: row[9]}
+
+		if mapRunes[mt.Rune] {
+			problem(line, "rune %q is already used for a tile or item on the map", row[0])
+		}
+		if other, exists := types[mt.Rune]; exists {
+			problem(line, "rune %q is already used by %s", row[0], other.Name)
+		}
+		if mt.Name == "" {
+			problem(line, "name is empty")
+		}
+
+		mt.Hitpoints, err = strconv.Atoi(row[2])
+		if err != nil || mt.Hitpoints <= 0 {
+			problem(line, "hitpoints %q should be a whole number above 0", row[2])
+		}
+		mt.Strength, err = strconv.Atoi(row[3])
+		if err != nil || mt.Strength < 0 {
+			problem(line, "strength %q should be a whole number, 0 or more", row[3])
+		}
+		mt.Defense, err = strconv.Atoi(row[4])
+		if err != nil {
+			problem(line, "defense %q should be a whole number", row[4])
+		}
+		mt.Accuracy, err = strconv.Atoi(row[5])
+		if err != nil {
+			problem(line, "accuracy %q should be a whole number", row[5])
+		}
+		mt.Damage, err = ParseDice(row[6])
+		if err != nil {
+			problem(line, "%v", err)
+		}
+		mt.Speed, err = strconv.ParseFloat(row[7], 64)
+		if err != nil || mt.Speed <= 0 {
+			problem(line, "speed %q should be a number above 0", row[7])
+		}
+		mt.SightRange, err = strconv.Atoi(row[8])
+		if err != nil || mt.SightRange < 0 {
+			problem(line, "sight range %q should be a whole number, 0 or more", row[8])
+		}
+		if _, exists := monsterBehaviors[mt.Behavior]; !exists {
+			problem(line, "unknown behavior %q", mt.Behavior)
+		}
+
+		mt.SpriteX, mt.SpriteY = -1, -1
+		if row[10] != "" || row[11] != "" {
+			mt.SpriteX, err = strconv.Atoi(row[10])
+			if err != nil || mt.SpriteX < 0 {
+				problem(line, "sprite x %q should be a whole number, 0 or more", row[10])
+			}
+			mt.SpriteY, err = strconv.Atoi(row[11])
+			if err != nil || mt.SpriteY < 0 {
+				problem(line, "sprite y %q should be a whole number, 0 or more", row[11])
+			}
+		}
+
+		types[mt.Rune] = mt
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(fileErr.Problems) > 0 {
+		return nil, fileErr
+	}
+	return types, nil
+}
+
+// monsterTypeList returns the monster types sorted by rune so random picks are repeatable
+func monsterTypeList() []*MonsterType {
+	list := make([]*MonsterType, 0, len(MonsterTypes))
+	for _, mt := range MonsterTypes {
+		list = append(list, mt)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Rune < list[j].Rune
+	})
+	return list
+}
@@ -0,0 +1,385 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Content packs add levels, tiles, monsters and sprites on top of the built in content. Every
+// directory in the packs directory with a pack.txt in it is a pack:
+//
+//	name = caves
+//	version = 1.2
+//	depends = torches, bats
+//	order = 10
+//
+// next to any of maps/*.map, data/tiles.txt, data/monsters.txt, and for ui2d assets/tiles.png with
+// its assets/atlas-index.txt. Packs load after the packs they depend on, otherwise by order and then
+// by name. A pack can't reuse a level name or a tile or monster rune that's already taken, packs that
+// try are all listed in a PackError. Pack maps link up to other levels with [stairs], world.txt
+// stays as it is
+
+// Pack is a content pack read from its pack.txt
+type Pack struct {
+	Name    string
+	Version string
+	Depends []string
+	Order   int
+	FS      fs.FS  // the packs directory
+	Dir     string // where the pack is in FS
+}
+
+// Path is where a file of the pack is in FS
+func (p *Pack) Path(name string) string {
+	return path.Join(p.Dir, name)
+}
+
+func (p *Pack) String() string {
+	return p.Name + " " + p.Version
+}
+
+// Packs are the loaded content packs in the order they were loaded
+var Packs []*Pack
+
+// PackTiles are the tiles added by packs, they end up in every level's TileMap
+var PackTiles = make(map[rune]Tile)
+
+// PackError lists everything wrong with the packs, conflicts between them included, so it can all be fixed in one go
+type PackError struct {
+	Problems []string
+}
+
+func (e *PackError) Error() string {
+	return fmt.Sprintf("content packs have %d problem(s):\n\t%s", len(e.Problems), strings.Join(e.Problems, "\n\t"))
+}
+
+// tileTypes are the tile types a pack can use, doors and stairs need code behind them
+var tileTypes = map[string]bool{"Wall": true, "Floor": true, "Water": true}
+
+// LoadPacks finds the packs in fsys and loads their tiles and monsters, the maps are loaded with the
+// rest by NewGame. Call it after LoadMonsterTypes. Nothing changes if any pack has a problem
+func LoadPacks(fsys fs.FS) error {
+	packErr := &PackError{}
+	problem := func(format string, a ...interface{}) {
+		packErr.Problems = append(packErr.Problems, fmt.Sprintf(format, a...))
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*Pack)
+	var found []*Pack
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		p, err := readManifest(fsys, e.Name())
+		if err != nil {
+			problem("%v", err)
+			continue
+		}
+		if other, exists := byName[p.Name]; exists {
+			problem("%s: there is already a pack called %s in %s", p.Path("pack.txt"), p.Name, other.Dir)
+			continue
+		}
+		byName[p.Name] = p
+		found = append(found, p)
+	}
+	packs := orderPacks(found, problem)
+
+	// who has every rune and level name so far, for the conflict messages
+	runeOwners := make(map[rune]string)
+	for r := range mapRunes {
+		runeOwners[r] = "the built in map runes"
+	}
+	for _, mt := range monsterTypeList() {
+		runeOwners[mt.Rune] = "the built in monster " + mt.Name
+	}
+	tileNames := make(map[string]string)
+	for name := range legendTiles {
+		tileNames[name] = "the built in tiles"
+	}
+	levelOwners := make(map[string]string)
+	if files, err := fs.Glob(Content, "maps/*.map"); err == nil {
+		for _, file := range files {
+			if mf, err := parseMapFile(Content, file); err == nil {
+				levelOwners[mf.name] = "the built in level in " + file
+			}
+		}
+	}
+
+	// later packs can use what earlier ones added, so it goes in straight away and gets put back on failure
+	oldMonsters, oldTiles := MonsterTypes, PackTiles
+	MonsterTypes = make(map[rune]*MonsterType)
+	for r, mt := range oldMonsters {
+		MonsterTypes[r] = mt
+	}
+	PackTiles = make(map[rune]Tile)
+
+	for _, p := range packs {
+		tilesPath := p.Path("data/tiles.txt")
+		if _, err := fs.Stat(fsys, tilesPath); err == nil {
+			tiles, err := parseTiles(fsys, tilesPath)
+			if err != nil {
+				problem("%v", err)
+			}
+			for _, t := range tiles {
+				if owner, exists := runeOwners[t.Rune]; exists {
+					problem("%s: rune '%s' of tile %s in %s is already used by %s", tilesPath, string(t.Rune), t.Name, p, owner)
+					continue
+				}
+				if owner, exists := tileNames[strings.ToLower(t.Name)]; exists {
+					problem("%s: tile name %s in %s is already used by %s", tilesPath, t.Name, p, owner)
+					continue
+				}
+				runeOwners[t.Rune] = "tile " + t.Name + " in " + p.String()
+				tileNames[strings.ToLower(t.Name)] = p.String()
+				PackTiles[t.Rune] = t
+			}
+		}
+
+		monstersPath := p.Path("data/monsters.txt")
+		if _, err := fs.Stat(fsys, monstersPath); err == nil {
+			types, err := parseMonsterTypes(fsys, monstersPath)
+			if err != nil {
+				problem("%v", err)
+			}
+			runes := make([]rune, 0, len(types))
+			for r := range types {
+				runes = append(runes, r)
+			}
+			sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+			for _, r := range runes {
+				mt := types[r]
+				if owner, exists := runeOwners[r]; exists {
+					problem("%s: rune '%s' of monster %s in %s is already used by %s", monstersPath, string(r), mt.Name, p, owner)
+					continue
+				}
+				runeOwners[r] = "monster " + mt.Name + " in " + p.String()
+				MonsterTypes[r] = mt
+			}
+		}
+
+		files, err := fs.Glob(fsys, p.Path("maps/*.map"))
+		if err != nil {
+			problem("%v", err)
+		}
+		for _, file := range files {
+			mf, err := parseMapFile(fsys, file)
+			if err != nil {
+				problem("%v", err)
+				continue
+			}
+			if owner, exists := levelOwners[mf.name]; exists {
+				problem("%s: level %s in %s is already %s", file, mf.name, p, owner)
+				continue
+			}
+			levelOwners[mf.name] = "the level in " + file
+		}
+	}
+
+	if len(packErr.Problems) > 0 {
+		MonsterTypes, PackTiles = oldMonsters, oldTiles
+		return packErr
+	}
+	Packs = packs
+	return nil
+}
+
+// readManifest reads dir/pack.txt, it has the same key = value lines as the map header
+func readManifest(fsys fs.FS, dir string) (*Pack, error) {
+	p := &Pack{FS: fsys, Dir: dir}
+	manifest := p.Path("pack.txt")
+	lines, err := readLines(fsys, manifest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: no pack.txt so it isn't a pack", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' {
+			continue
+		}
+		key, value, found := cutString(line, "=")
+		if !found {
+			return nil, &MapError{manifest, i + 1, fmt.Errorf("expected key = value, got %q", line)}
+		}
+		switch key {
+		case "name":
+			p.Name = value
+		case "version":
+			p.Version = value
+		case "depends":
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					p.Depends = append(p.Depends, name)
+				}
+			}
+		case "order":
+			p.Order, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, &MapError{manifest, i + 1, fmt.Errorf("order %q should be a whole number", value)}
+			}
+		default:
+			return nil, &MapError{manifest, i + 1, fmt.Errorf("unknown key %q", key)}
+		}
+	}
+	if p.Name == "" || p.Version == "" {
+		return nil, &MapError{manifest, 0, fmt.Errorf("needs a name and a version")}
+	}
+	return p, nil
+}
+
+// orderPacks puts every pack after the ones it depends on, otherwise by Order and then by name.
+// Packs that can't be loaded are reported and left out
+func orderPacks(packs []*Pack, problem func(format string, a ...interface{})) []*Pack {
+	sorted := append([]*Pack{}, packs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Order != sorted[j].Order {
+			return sorted[i].Order < sorted[j].Order
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	exists := make(map[string]bool)
+	for _, p := range sorted {
+		exists[p.Name] = true
+	}
+	for _, p := range sorted {
+		for _, dep := range p.Depends {
+			if !exists[dep] {
+				problem("%s: %s depends on %s which isn't in the packs directory", p.Path("pack.txt"), p, dep)
+			}
+		}
+	}
+
+	loaded := make(map[string]bool)
+	var ordered []*Pack
+	for {
+		// the first pack that's ready each time round so order only matters between ready packs
+		var next *Pack
+		for _, p := range sorted {
+			if loaded[p.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range p.Depends {
+				ready = ready && loaded[dep]
+			}
+			if ready {
+				next = p
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		loaded[next.Name] = true
+		ordered = append(ordered, next)
+	}
+
+	for _, p := range sorted {
+		if loaded[p.Name] {
+			continue
+		}
+		var waiting []string
+		for _, dep := range p.Depends {
+			if exists[dep] && !loaded[dep] {
+				waiting = append(waiting, dep)
+			}
+		}
+		if len(waiting) > 0 {
+			problem("%s: %s depends on %s which can't be loaded first, are they depending on each other?", p.Path("pack.txt"), p, strings.Join(waiting, ", "))
+		}
+	}
+	return ordered
+}
+
+// parseTiles reads a pack's tile definitions, lines are rune, name, type, cost, light with the
+// type being Wall, Floor or Water
+func parseTiles(fsys fs.FS, path string) ([]Tile, error) {
+	lines, err := readLines(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiles []Tile
+	seen := make(map[rune]bool)
+	for i, text := range lines {
+		lineNum := i + 1
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") && !strings.HasPrefix(text, "#,") {
+			continue
+		}
+		row := strings.Split(text, ",")
+		for j := range row {
+			row[j] = strings.TrimSpace(row[j])
+		}
+		if len(row) != 5 {
+			return nil, &MapError{path, lineNum, fmt.Errorf("expected 5 fields (rune, name, type, cost, light) but found %d", len(row))}
+		}
+
+		runes := []rune(row[0])
+		if len(runes) != 1 || runes[0] == ' ' {
+			return nil, &MapError{path, lineNum, fmt.Errorf("rune %q has to be a single character", row[0])}
+		}
+		if seen[runes[0]] {
+			return nil, &MapError{path, lineNum, fmt.Errorf("rune %q is in here twice", row[0])}
+		}
+		seen[runes[0]] = true
+		t := Tile{Rune: runes[0], Name: row[1], Type: row[2], HasFloor: row[2] != "Wall"}
+		if t.Name == "" {
+			return nil, &MapError{path, lineNum, fmt.Errorf("name is empty")}
+		}
+		if !tileTypes[t.Type] {
+			return nil, &MapError{path, lineNum, fmt.Errorf("type %q should be Wall, Floor or Water", t.Type)}
+		}
+		t.Cost, err = strconv.Atoi(row[3])
+		if err != nil || t.Cost < 0 || t.HasFloor && t.Cost == 0 {
+			return nil, &MapError{path, lineNum, fmt.Errorf("cost %q should be a whole number, above 0 for anything but walls", row[3])}
+		}
+		t.Light, err = strconv.Atoi(row[4])
+		if err != nil || t.Light < 0 {
+			return nil, &MapError{path, lineNum, fmt.Errorf("light %q should be a whole number, 0 or more", row[4])}
+		}
+		tiles = append(tiles, t)
+	}
+	return tiles, nil
+}
+
+// contentFile is a file in one of the filesystems content comes from
+type contentFile struct {
+	fsys fs.FS
+	path string
+}
+
+// mapFiles lists the maps in fsys and then the ones in every loaded pack
+func mapFiles(fsys fs.FS) ([]contentFile, error) {
+	var files []contentFile
+	paths, err := fs.Glob(fsys, "maps/*.map")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		files = append(files, contentFile{fsys, p})
+	}
+	for _, pack := range Packs {
+		paths, err := fs.Glob(pack.FS, pack.Path("maps/*.map"))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			files = append(files, contentFile{pack.FS, p})
+		}
+	}
+	return files, nil
+}
@@ -4,106 +4,104 @@ import (
 	"math"
 )
 
+// bfsearch returns the closest position to start that found says yes to, only going over walkable tiles
 // Might be useful for flood effects and stuff
-func (level *Level) bfsearch(start Pos) {
+func (level *Level) bfsearch(start Pos, walkable func(*Level, Pos) bool, found func(Pos) bool) (Pos, bool) {
 	edge := make([]Pos, 0, 8)
 	edge = append(edge, start)
 	visited := make(map[Pos]bool)
 	visited[start] = true
-	level.Debug = visited
 
 	for len(edge) > 0 {
 		current := edge[0]
 		edge = edge[1:]
-		for _, next := range getNeighbours(level, current) {
+		if found(current) {
+			return current, true
+		}
+		for _, next := range getNeighbours(level, current, walkable) {
 			if !visited[next] {
 				edge = append(edge, next)
 				visited[next] = true
 			}
 		}
 	}
+	return Pos{}, false
 }
 
+// bresenham is the line of tiles from start to end, both included, up to the first tile that can't
+// be seen through. That tile is still in the line. The line is worked out from the same end
+// whichever way round it's asked for, so from end to start it's the same tiles backwards
 func (level *Level) bresenham(start Pos, end Pos) []Pos {
-	var line []Pos
-	steep := math.Abs(float64(end.Y-start.Y)) > math.Abs(float64(end.X-start.X))
-	if steep {
-		start.X, start.Y = start.Y, start.X
-		end.X, end.Y = end.Y, end.X
-	}
-
-	deltaY := int(math.Abs(float64(end.Y - start.Y)))
-	err := 0
-	y := start.Y
-	ystep := 1
-	if start.Y >= end.Y {
-		ystep = -1
-	}
-
-	if start.X > end.X {
-		deltaX := start.X - end.X
-		for x := start.X; x > end.X; x-- {
-			var pos Pos
-			if steep {
-				pos = Pos{y, x}
-			} else {
-				pos = Pos{x, y}
-			}
-			line = append(line, pos)
-			if !canSeeThrough(level, pos) {
-				break
-			}
-			err += deltaY
-			if 2*err >= deltaX {
-				y += ystep
-				err -= deltaX
-			}
+	reversed := end.X < start.X || end.X == start.X && end.Y < start.Y
+	if reversed {
+		start, end = end, start
+	}
+
+	dx, dy := end.X-start.X, end.Y-start.Y
+	if dy < 0 {
+		dy = -dy
+	}
+	stepX, stepY := sign(end.X-start.X), sign(end.Y-start.Y)
+	err := dx - dy
+	line := []Pos{start}
+	for pos := start; pos != end; {
+		e2 := 2 * err
+		if e2 >= -dy {
+			err -= dy
+			pos.X += stepX
 		}
-	} else {
-		deltaX := end.X - start.X
-		for x := start.X; x < end.X; x++ {
-			var pos Pos
-			if steep {
-				pos = Pos{y, x}
-			} else {
-				pos = Pos{x, y}
-			}
-			line = append(line, pos)
-			if !canSeeThrough(level, pos) {
-				break
-			}
-			err += deltaY
-			if 2*err >= deltaX {
-				y += ystep
-				err -= deltaX
-			}
+		if e2 <= dx {
+			err += dx
+			pos.Y += stepY
+		}
+		line = append(line, pos)
+	}
+
+	if reversed {
+		for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+			line[i], line[j] = line[j], line[i]
+		}
+	}
+	for i, pos := range line {
+		if !canSeeThrough(level, pos) {
+			return line[:i+1]
 		}
 	}
 	return line
 }
 
 func (level *Level) astar(from, to Pos) (path []Pos, dist int, found bool) {
+	return level.findPath(from, to, canWalk)
+}
+
+// findPath is astar only going through tiles walkable says yes to
+func (level *Level) findPath(from, to Pos, walkable func(*Level, Pos) bool) (path []Pos, dist int, found bool) {
 	// fmt.Printf("start: {%d, %d}\ngoal: {%d, %d}\n", from.X, from.Y, to.X, to.Y)
-	edge := make(pqueue, 0, 8)
-	edge = edge.push(from, 1)
-	prevPos := make(map[Pos]Pos)
-	prevPos[from] = from
-	currentCost := make(map[Pos]int)
-	currentCost[from] = 0
+	edge := NewPriorityQueue[Pos]()
+	edge.Push(from, 1)
+	// costs and the way back are kept in grids the size of the level, it's a lot quicker than maps
+	width := len(level.Level[0])
+	at := func(pos Pos) int { return pos.Y*width + pos.X }
+	prevPos := make([]Pos, width*len(level.Level))
+	currentCost := make([]int, len(prevPos))
+	reached := make([]bool, len(prevPos))
+	prevPos[at(from)] = from
+	reached[at(from)] = true
+	numReached := 1
 
 	var current Pos
 	for {
-		if len(edge) == 0 {
+		if edge.Len() == 0 {
 			return
 		}
 
-		edge, current = edge.pop()
+		current, _ = edge.Pop()
 		if current == to {
 			path := make([]Pos, 0)
 			p := current
 			for p != from {
 				path = append(path, p)
-				p = prevPos[p]
+				p = prevPos[at(p)]
 			}
 			path = append(path, p)
 			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
@@ -113,49 +111,196 @@ func (level *Level) astar(from, to Pos) (path []Pos, dist int, found bool) {
 			// 	level.Debug[pos] = true
 			// }
 
-			return path, len(currentCost), true
+			return path, numReached, true
 		}
 
-		for _, next := range getNeighbours(level, current) {
+		for _, next := range getNeighbours(level, current, walkable) {
 			t := level.TileAtPos(next)
-			newCost := currentCost[current] + t.Cost
-			_, exists := currentCost[next]
-			if !exists || newCost < currentCost[next] {
-				currentCost[next] = newCost
+			newCost := currentCost[at(current)] + t.Cost
+			if !reached[at(next)] || newCost < currentCost[at(next)] {
+				if !reached[at(next)] {
+					reached[at(next)] = true
+					numReached++
+				}
+				currentCost[at(next)] = newCost
+				// chebyshev distance, a diagonal step costs the same as a straight one
 				xDist := int(math.Abs(float64(to.X - next.X)))
 				yDist := int(math.Abs(float64(to.Y - next.Y)))
-				priority := newCost + xDist + yDist
-				edge = edge.push(next, priority)
+				priority := newCost + xDist
+				if yDist > xDist {
+					priority = newCost + yDist
+				}
+				edge.Push(next, priority)
 				// level.Debug[next] = true
-				prevPos[next] = current
+				prevPos[at(next)] = current
 				//fmt.Printf("{%d, %d} to {%d, %d} cost: %d\n",current.X, current.Y, next.X, next.Y, newCost)
 			}
 		}
 	}
 }
 
-func getNeighbours(level *Level, pos Pos) []Pos {
-	neighbours := make([]Pos, 0, 4)
-	u := Pos{pos.X, pos.Y - 1}
-	d := Pos{pos.X, pos.Y + 1}
-	l := Pos{pos.X - 1, pos.Y}
-	r := Pos{pos.X + 1, pos.Y}
+// CornerRule decides when a diagonal step can squeeze past walls and closed doors beside it
+type CornerRule int
+
+const (
+	CornersNever   CornerRule = iota // both tiles beside the step have to be open
+	CornersOneSide                   // one open tile beside the step is enough
+	CornersAlways                    // diagonal steps don't care what's beside them
+)
+
+// Corners applies to the player and the monsters
+var Corners = CornersNever
+
+// directions are the straight ones first then the diagonals
+var directions = []Pos{{0, -1}, {0, 1}, {-1, 0}, {1, 0}, {-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
 
-	if canWalk(level, u) {
-		neighbours = append(neighbours, u)
+func blocksCorner(level *Level, pos Pos) bool {
+	if !inRange(level, pos) {
+		return true
 	}
+	switch level.TileAtPos(pos).Type {
+	case "Wall", "ClosedDoor", "Empty":
+		return true
+	}
+	return false
+}
 
-	if canWalk(level, d) {
-		neighbours = append(neighbours, d)
+// canStep checks the corner rule for a step to a neighbouring tile, it doesn't check the tile itself
+func canStep(level *Level, from, to Pos) bool {
+	if from.X == to.X || from.Y == to.Y {
+		return true
 	}
+	blockedX := blocksCorner(level, Pos{to.X, from.Y})
+	blockedY := blocksCorner(level, Pos{from.X, to.Y})
+	switch Corners {
+	case CornersAlways:
+		return true
+	case CornersOneSide:
+		return !blockedX || !blockedY
+	default:
+		return !blockedX && !blockedY
+	}
+}
 
-	if canWalk(level, l) {
-		neighbours = append(neighbours, l)
+func getNeighbours(level *Level, pos Pos, walkable func(*Level, Pos) bool) []Pos {
+	neighbours := make([]Pos, 0, 8)
+	for _, d := range directions {
+		next := Pos{pos.X + d.X, pos.Y + d.Y}
+		if inRange(level, next) && walkable(level, next) && canStep(level, pos, next) {
+			neighbours = append(neighbours, next)
+		}
 	}
+	return neighbours
+}
+
+// Unreachable is the distance in a DijkstraMap of tiles none of the goals can be got to from
+const Unreachable = math.MaxInt32
 
-	if canWalk(level, r) {
-		neighbours = append(neighbours, r)
+// Goal is somewhere a DijkstraMap leads to. Cost is what the distance starts at on it, a goal with a
+// higher one is less attractive and one below 0 is worth going out of the way for
+type Goal struct {
+	Pos
+	Cost int
+}
+
+// DijkstraMap is how far every tile is from the nearest goal, counting tile Cost the way findPath
+// does, so walking downhill from anywhere ends up at a goal. It can be reused until the tiles change
+type DijkstraMap struct {
+	Dist [][]int // [y][x] like Level.Level
+}
+
+// DijkstraMap works out the distance to goals for every tile walkable says yes to
+func (level *Level) DijkstraMap(goals []Goal, walkable func(*Level, Pos) bool) *DijkstraMap {
+	dm := &DijkstraMap{Dist: make([][]int, len(level.Level))}
+	for y := range level.Level {
+		dm.Dist[y] = make([]int, len(level.Level[y]))
+		for x := range dm.Dist[y] {
+			dm.Dist[y][x] = Unreachable
+		}
 	}
 
-	return neighbours
+	edge := NewPriorityQueue[Pos]()
+	for _, goal := range goals {
+		if inRange(level, goal.Pos) && goal.Cost < dm.Dist[goal.Y][goal.X] {
+			dm.Dist[goal.Y][goal.X] = goal.Cost
+			edge.Push(goal.Pos, goal.Cost)
+		}
+	}
+
+	for edge.Len() > 0 {
+		current, _ := edge.Pop()
+		// going the other way whoever is on next steps onto current, so that's the tile that costs
+		cost := dm.Dist[current.Y][current.X] + level.TileAtPos(current).Cost
+		for _, next := range getNeighbours(level, current, walkable) {
+			if cost < dm.Dist[next.Y][next.X] {
+				dm.Dist[next.Y][next.X] = cost
+				edge.Push(next, cost)
+			}
+		}
+	}
+	return dm
+}
+
+// At is the distance at pos, Unreachable outside the map
+func (dm *DijkstraMap) At(pos Pos) int {
+	if pos.Y < 0 || pos.Y >= len(dm.Dist) || pos.X < 0 || pos.X >= len(dm.Dist[pos.Y]) {
+		return Unreachable
+	}
+	return dm.Dist[pos.Y][pos.X]
+}
+
+// Invert makes a map for getting away from the goals. Going downhill on it heads away from them but
+// takes a way round past them rather than running into a dead end, the bigger factor is the further
+// out of the way it'll go for that. Around 1.2 works
+func (dm *DijkstraMap) Invert(level *Level, walkable func(*Level, Pos) bool, factor float64) *DijkstraMap {
+	var goals []Goal
+	for y, row := range dm.Dist {
+		for x, d := range row {
+			if d != Unreachable {
+				goals = append(goals, Goal{Pos{x, y}, int(math.Round(-float64(d) * factor))})
+			}
+		}
+	}
+	return level.DijkstraMap(goals, walkable)
+}
+
+// CombineMaps adds up maps made for the same level, each times its weight, weights that are missing
+// count as 1. A tile any of the maps can't reach can't be reached on the combined one either
+func CombineMaps(maps []*DijkstraMap, weights []float64) *DijkstraMap {
+	if len(maps) == 0 {
+		return &DijkstraMap{}
+	}
+	combined := &DijkstraMap{Dist: make([][]int, len(maps[0].Dist))}
+	for y := range combined.Dist {
+		combined.Dist[y] = make([]int, len(maps[0].Dist[y]))
+		for x := range combined.Dist[y] {
+			sum := 0.0
+			for i, dm := range maps {
+				d := dm.At(Pos{x, y})
+				if d == Unreachable {
+					sum = Unreachable
+					break
+				}
+				weight := 1.0
+				if i < len(weights) {
+					weight = weights[i]
+				}
+				sum += float64(d) * weight
+			}
+			combined.Dist[y][x] = int(math.Round(sum))
+		}
+	}
+	return combined
+}
+
+// Downhill is the neighbour of from walkable says yes to with the lowest distance, as long as that's
+// lower than where from is. Ties go to the first in directions so it's the same every run
+func (dm *DijkstraMap) Downhill(level *Level, from Pos, walkable func(*Level, Pos) bool) (Pos, bool) {
+	best, bestDist := from, dm.At(from)
+	for _, n := range getNeighbours(level, from, walkable) {
+		if d := dm.At(n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+	return best, best != from
 }
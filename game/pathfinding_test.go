@@ -0,0 +1,554 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// fixture makes a level out of rows written like a map file. Tile runes become those tiles, anything
+// else is floor and gets returned as a marker so tests can name positions with letters
+func fixture(t testing.TB, rows ...string) (*Level, map[rune]Pos) {
+	t.Helper()
+	level := &Level{Monsters: make(map[Pos]*Monster), Items: make(map[Pos][]*Item), Debug: make(map[Pos]bool)}
+	level.LoadTileMap()
+	markers := make(map[rune]Pos)
+	for y, row := range rows {
+		runes := []rune(row)
+		if y > 0 && len(runes) != len(level.Level[0]) {
+			t.Fatalf("fixture row %d is %d wide, not %d", y, len(runes), len(level.Level[0]))
+		}
+		level.Level = append(level.Level, make([]Tile, len(runes)))
+		for x, r := range runes {
+			tile, exists := level.TileMap[r]
+			if !exists || r == Empty {
+				markers[r] = Pos{x, y}
+				tile = level.TileMap[DirtFloor]
+			}
+			level.Level[y][x] = tile
+		}
+	}
+	return level, markers
+}
+
+func TestDijkstraMapGoals(t *testing.T) {
+	level, m := fixture(t,
+		"##########",
+		"#A......B#",
+		"##########",
+	)
+	tests := []struct {
+		name  string
+		goals []Goal
+		want  []int // along the row from A to B
+	}{
+		{"one goal", []Goal{{m['A'], 0}}, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"two goals", []Goal{{m['A'], 0}, {m['B'], 0}}, []int{0, 1, 2, 3, 3, 2, 1, 0}},
+		{"weighted goal", []Goal{{m['A'], 0}, {m['B'], 3}}, []int{0, 1, 2, 3, 4, 5, 4, 3}},
+		{"negative goal", []Goal{{m['A'], 0}, {m['B'], -5}}, []int{0, 1, 0, -1, -2, -3, -4, -5}},
+		{"same goal twice keeps the lowest", []Goal{{m['A'], 4}, {m['A'], 1}}, []int{1, 2, 3, 4, 5, 6, 7, 8}},
+	}
+	for _, tt := range tests {
+		dm := level.DijkstraMap(tt.goals, canWalk)
+		for i, want := range tt.want {
+			pos := Pos{m['A'].X + i, 1}
+			if got := dm.At(pos); got != want {
+				t.Errorf("%s: %v is %d, want %d", tt.name, pos, got, want)
+			}
+		}
+		if dm.At(Pos{0, 0}) != Unreachable || dm.At(Pos{-1, 1}) != Unreachable || dm.At(Pos{10, 1}) != Unreachable {
+			t.Errorf("%s: walls and outside the level should be unreachable", tt.name)
+		}
+	}
+}
+
+func TestDijkstraMapTileCost(t *testing.T) {
+	level, m := fixture(t,
+		"#######",
+		"#A~~~B#",
+		"#######",
+	)
+	dm := level.DijkstraMap([]Goal{{m['A'], 0}}, canWalk)
+	// a step costs the tile stepped onto like in findPath, water costs 2
+	for i, want := range []int{0, 1, 3, 5, 7} {
+		if got := dm.At(Pos{1 + i, 1}); got != want {
+			t.Errorf("%d,1 is %d, want %d", 1+i, got, want)
+		}
+	}
+
+	// with a way round the water it's cheaper to go round
+	level, m = fixture(t,
+		"#######",
+		"#A~~~B#",
+		"#.....#",
+		"#######",
+	)
+	dm = level.DijkstraMap([]Goal{{m['A'], 0}}, canWalk)
+	if got := dm.At(m['B']); got != 4 {
+		t.Errorf("B is %d, want 4 going round the water", got)
+	}
+	if _, cost := pathCost(t, level, m['B'], m['A']); cost != dm.At(m['B']) {
+		t.Errorf("astar costs %d but the map says %d", cost, dm.At(m['B']))
+	}
+}
+
+func TestCombineMaps(t *testing.T) {
+	level, m := fixture(t,
+		"#######",
+		"#A...B#",
+		"#######",
+		"#C....#",
+		"#######",
+	)
+	a := level.DijkstraMap([]Goal{{m['A'], 0}}, canWalk)
+	b := level.DijkstraMap([]Goal{{m['B'], 0}}, canWalk)
+	combined := CombineMaps([]*DijkstraMap{a, b}, []float64{1, -0.5})
+	// a goes 0 to 4 along the row and b 4 to 0, halves round away from zero
+	for i, want := range []int{-2, -1, 1, 3, 4} {
+		pos := Pos{1 + i, 1}
+		if got := combined.At(pos); got != want {
+			t.Errorf("%v is %d, want %d", pos, got, want)
+		}
+	}
+	if got := combined.At(m['C']); got != Unreachable {
+		t.Errorf("C can't be reached on either map but combined it's %d", got)
+	}
+
+	// missing weights count as 1
+	if got := CombineMaps([]*DijkstraMap{a, b}, nil).At(Pos{3, 1}); got != 4 {
+		t.Errorf("3,1 is %d, want 2+2", got)
+	}
+	if got := CombineMaps(nil, nil).At(m['A']); got != Unreachable {
+		t.Errorf("combining nothing gives %d at A, want Unreachable", got)
+	}
+}
+
+func TestInvertLeadsAway(t *testing.T) {
+	level, m := fixture(t,
+		"#########",
+		"#.......#",
+		"#..P....#",
+		"#...M...#",
+		"#.......#",
+		"#########",
+	)
+	toPlayer := level.DijkstraMap([]Goal{{m['P'], 0}}, canWalk)
+	away := toPlayer.Invert(level, canWalk, 1.2)
+
+	pos := m['M']
+	for steps := 0; steps < 10; steps++ {
+		next, found := away.Downhill(level, pos, canWalk)
+		if !found {
+			break
+		}
+		if toPlayer.At(next) < toPlayer.At(pos) {
+			t.Fatalf("running away went from %v to %v, closer to the player", pos, next)
+		}
+		pos = next
+	}
+	if toPlayer.At(pos) <= toPlayer.At(m['M']) {
+		t.Errorf("ended up at %v which isn't further from the player than %v", pos, m['M'])
+	}
+}
+
+func TestDownhill(t *testing.T) {
+	level, m := fixture(t,
+		"#######",
+		"#.....#",
+		"#L.X.R#",
+		"#.....#",
+		"#######",
+	)
+	dm := level.DijkstraMap([]Goal{{m['L'], 0}, {m['R'], 0}}, canWalk)
+
+	// left and right both get one closer, directions has left first
+	next, found := dm.Downhill(level, m['X'], canWalk)
+	if !found || next != (Pos{m['X'].X - 1, m['X'].Y}) {
+		t.Errorf("downhill from X went to %v %v, want the tile to the left", next, found)
+	}
+	// above X the diagonals down are just as good as left and right, straight steps win
+	next, _ = dm.Downhill(level, Pos{3, 1}, canWalk)
+	if next != (Pos{2, 1}) {
+		t.Errorf("downhill from 3,1 went to %v, want 2,1", next)
+	}
+	// nowhere lower than a goal
+	if next, found := dm.Downhill(level, m['L'], canWalk); found || next != m['L'] {
+		t.Errorf("downhill from a goal went to %v", next)
+	}
+	// monsters in the way are skipped by walkable
+	level.Monsters[Pos{2, 2}] = &Monster{}
+	next, _ = dm.Downhill(level, m['X'], canWalk)
+	if next != (Pos{4, 2}) {
+		t.Errorf("downhill from X with a monster on the left went to %v, want 4,2", next)
+	}
+}
+
+// pathCost is the astar path from a to b and what it costs
+func pathCost(t testing.TB, level *Level, from, to Pos) ([]Pos, int) {
+	t.Helper()
+	path, _, found := level.astar(from, to)
+	if !found {
+		t.Fatalf("no path from %v to %v", from, to)
+	}
+	cost := 0
+	for _, pos := range path[1:] {
+		cost += level.TileAtPos(pos).Cost
+	}
+	return path, cost
+}
+
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		walkable func(*Level, Pos) bool
+		found    bool
+		cost     int
+	}{
+		{"straight", []string{
+			"######",
+			"#A..B#",
+			"######",
+		}, canWalk, true, 3},
+		{"diagonal", []string{
+			"#####",
+			"#A..#",
+			"#...#",
+			"#..B#",
+			"#####",
+		}, canWalk, true, 2},
+		{"round a wall", []string{
+			"#####",
+			"#A#B#",
+			"#.#.#",
+			"#...#",
+			"#####",
+		}, canWalk, true, 6},
+		{"round the water", []string{
+			"#######",
+			"#A~~~B#",
+			"#.....#",
+			"#######",
+		}, canWalk, true, 4},
+		{"through the water", []string{
+			"#######",
+			"#A~~~B#",
+			"#######",
+		}, canWalk, true, 7},
+		{"walled in", []string{
+			"#####",
+			"#A#B#",
+			"#####",
+		}, canWalk, false, 0},
+		{"closed door", []string{
+			"#####",
+			"#A|B#",
+			"#####",
+		}, canWalk, false, 0},
+		{"closed door when doors can be opened", []string{
+			"#####",
+			"#A|B#",
+			"#####",
+		}, passable, true, 3},
+		{"open door", []string{
+			"#####",
+			"#A/B#",
+			"#####",
+		}, canWalk, true, 2},
+	}
+	for _, tt := range tests {
+		level, m := fixture(t, tt.rows...)
+		path, _, found := level.findPath(m['A'], m['B'], tt.walkable)
+		if found != tt.found {
+			t.Errorf("%s: found %v, want %v", tt.name, found, tt.found)
+			continue
+		}
+		if !found {
+			continue
+		}
+		checkPath(t, level, path, m['A'], m['B'], tt.walkable)
+		if cost := costOf(level, path); cost != tt.cost {
+			t.Errorf("%s: path %v costs %d, want %d", tt.name, path, cost, tt.cost)
+		}
+	}
+}
+
+func TestAstarAvoidsMonsters(t *testing.T) {
+	level, m := fixture(t,
+		"#####",
+		"#...#",
+		"#AMB#",
+		"#...#",
+		"#####",
+	)
+	level.Monsters[m['M']] = &Monster{}
+	path, cost := pathCost(t, level, m['A'], m['B'])
+	checkPath(t, level, path, m['A'], m['B'], canWalk)
+	if cost != 2 {
+		t.Errorf("going round the monster cost %d, want 2", cost)
+	}
+
+	// boxed in by monsters there's no way
+	for _, pos := range []Pos{{2, 1}, {2, 3}} {
+		level.Monsters[pos] = &Monster{}
+	}
+	if _, _, found := level.astar(m['A'], m['B']); found {
+		t.Error("found a way through a wall of monsters")
+	}
+}
+
+func TestBFSearch(t *testing.T) {
+	level, m := fixture(t,
+		"#########",
+		"#S..x#.y#",
+		"#....#..#",
+		"#########",
+	)
+	tests := []struct {
+		name    string
+		targets []rune
+		want    rune
+		found   bool
+	}{
+		{"nearest", []rune{'x', 'y'}, 'x', true},
+		{"only target", []rune{'x'}, 'x', true},
+		{"behind a wall", []rune{'y'}, 0, false},
+		{"start itself", []rune{'S'}, 'S', true},
+	}
+	for _, tt := range tests {
+		targets := make(map[Pos]bool)
+		for _, r := range tt.targets {
+			targets[m[r]] = true
+		}
+		got, found := level.bfsearch(m['S'], canWalk, func(pos Pos) bool { return targets[pos] })
+		if found != tt.found || found && got != m[tt.want] {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, got, found, m[tt.want], tt.found)
+		}
+	}
+}
+
+func TestGetNeighboursCorners(t *testing.T) {
+	defer func(rule CornerRule) { Corners = rule }(Corners)
+
+	// X has walls up and left, the diagonal up left squeezes between two walls and up right and
+	// down left go past one
+	level, m := fixture(t,
+		"#####",
+		"#.#.#",
+		"##X.#",
+		"#...#",
+		"#####",
+	)
+	x := m['X']
+	tests := []struct {
+		rule CornerRule
+		want []Pos
+	}{
+		{CornersNever, []Pos{{2, 3}, {3, 2}, {3, 3}}},
+		{CornersOneSide, []Pos{{2, 3}, {3, 2}, {3, 1}, {1, 3}, {3, 3}}},
+		{CornersAlways, []Pos{{2, 3}, {3, 2}, {1, 1}, {3, 1}, {1, 3}, {3, 3}}},
+	}
+	for _, tt := range tests {
+		Corners = tt.rule
+		got := getNeighbours(level, x, canWalk)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("corner rule %d: got %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+
+	// a corner at the edge of the level counts as blocked
+	Corners = CornersNever
+	level, _ = fixture(t,
+		"..",
+		"..",
+	)
+	if got := getNeighbours(level, Pos{0, 0}, canWalk); len(got) != 3 {
+		t.Errorf("corner of an open level has %v as neighbours, want 3", got)
+	}
+}
+
+func TestBresenham(t *testing.T) {
+	level, _ := fixture(t, openRows(20, 20)...)
+	tests := []struct {
+		start, end Pos
+	}{
+		{Pos{2, 2}, Pos{10, 5}},
+		{Pos{10, 5}, Pos{2, 2}},
+		{Pos{3, 3}, Pos{3, 3}},
+		{Pos{1, 18}, Pos{18, 1}},
+		{Pos{5, 1}, Pos{5, 17}},
+		{Pos{1, 9}, Pos{17, 9}},
+		{Pos{4, 4}, Pos{9, 9}},
+		{Pos{12, 2}, Pos{1, 15}},
+	}
+	for _, tt := range tests {
+		line := level.bresenham(tt.start, tt.end)
+		if line[0] != tt.start || line[len(line)-1] != tt.end {
+			t.Errorf("line from %v to %v goes from %v to %v", tt.start, tt.end, line[0], line[len(line)-1])
+		}
+		if len(line) != chebyshev(tt.start, tt.end)+1 {
+			t.Errorf("line from %v to %v has %d tiles, want %d", tt.start, tt.end, len(line), chebyshev(tt.start, tt.end)+1)
+		}
+		for i := 1; i < len(line); i++ {
+			if chebyshev(line[i-1], line[i]) != 1 {
+				t.Errorf("line from %v to %v jumps from %v to %v", tt.start, tt.end, line[i-1], line[i])
+			}
+		}
+	}
+}
+
+func TestBresenhamSymmetry(t *testing.T) {
+	level, _ := fixture(t, openRows(16, 16)...)
+	for a := 0; a < 16*16; a++ {
+		for b := 0; b < 16*16; b += 7 {
+			start, end := Pos{a % 16, a / 16}, Pos{b % 16, b / 16}
+			there := level.bresenham(start, end)
+			back := level.bresenham(end, start)
+			if len(there) != len(back) {
+				t.Fatalf("%v to %v has %d tiles but back has %d", start, end, len(there), len(back))
+			}
+			for i := range there {
+				if there[i] != back[len(back)-1-i] {
+					t.Fatalf("%v to %v is %v but back is %v", start, end, there, back)
+				}
+			}
+		}
+	}
+}
+
+func TestBresenhamStopsAtWalls(t *testing.T) {
+	level, m := fixture(t,
+		"#######",
+		"#A.#.B#",
+		"#######",
+	)
+	line := level.bresenham(m['A'], m['B'])
+	want := []Pos{{1, 1}, {2, 1}, {3, 1}}
+	if fmt.Sprint(line) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v with the wall in it", line, want)
+	}
+}
+
+func FuzzAstar(f *testing.F) {
+	f.Add(int64(1), uint8(8), uint8(8), uint8(0))
+	f.Add(int64(2), uint8(16), uint8(5), uint8(1))
+	f.Add(int64(3), uint8(3), uint8(12), uint8(2))
+	f.Fuzz(func(t *testing.T, seed int64, w, h, corners uint8) {
+		defer func(rule CornerRule) { Corners = rule }(Corners)
+		Corners = CornerRule(corners % 3)
+
+		r := rand.New(rand.NewSource(seed))
+		width, height := 2+int(w)%20, 2+int(h)%20
+		rows := make([]string, height)
+		for y := range rows {
+			row := make([]rune, width)
+			for x := range row {
+				row[x] = []rune("..........##~|/")[r.Intn(15)]
+			}
+			rows[y] = string(row)
+		}
+		level, _ := fixture(t, rows...)
+		from := Pos{r.Intn(width), r.Intn(height)}
+		to := Pos{r.Intn(width), r.Intn(height)}
+		for i := r.Intn(4); i > 0; i-- {
+			pos := Pos{r.Intn(width), r.Intn(height)}
+			if pos != from && pos != to {
+				level.Monsters[pos] = &Monster{}
+			}
+		}
+		level.Level[from.Y][from.X] = level.TileMap[DirtFloor]
+		level.Level[to.Y][to.X] = level.TileMap[DirtFloor]
+
+		want := bruteForceCost(level, from, to)
+		path, _, found := level.astar(from, to)
+		if found != (want != Unreachable) {
+			t.Fatalf("astar found a way %v, brute force cost %d\n%s", found, want, strings.Join(rows, "\n"))
+		}
+		if !found {
+			return
+		}
+		checkPath(t, level, path, from, to, canWalk)
+		if cost := costOf(level, path); cost != want {
+			t.Fatalf("astar path %v costs %d, the cheapest is %d\n%s", path, cost, want, strings.Join(rows, "\n"))
+		}
+	})
+}
+
+func BenchmarkAstar(b *testing.B) {
+	// the spiders are still there so the path has to go round them
+	level := newTestGame(b, 0).Levels["level2"]
+	from, _ := level.findTile(UpStairs)
+	to, _ := level.findTile(DownStairs)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, found := level.astar(from, to); !found {
+			b.Fatal("no path between the stairs on level2")
+		}
+	}
+}
+
+// checkPath fails the test unless path goes from one neighbour to the next from from to to, over
+// tiles walkable says yes to and without cutting any corners it shouldn't
+func checkPath(t testing.TB, level *Level, path []Pos, from, to Pos, walkable func(*Level, Pos) bool) {
+	t.Helper()
+	if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+		t.Fatalf("path %v doesn't go from %v to %v", path, from, to)
+	}
+	for i := 1; i < len(path); i++ {
+		prev, pos := path[i-1], path[i]
+		switch {
+		case chebyshev(prev, pos) != 1:
+			t.Fatalf("path %v jumps from %v to %v", path, prev, pos)
+		case !canStep(level, prev, pos):
+			t.Fatalf("path %v cuts the corner from %v to %v", path, prev, pos)
+		case !walkable(level, pos):
+			t.Fatalf("path %v goes over %v which can't be walked on", path, pos)
+		}
+	}
+}
+
+// costOf is what walking path costs, the start is free
+func costOf(level *Level, path []Pos) int {
+	cost := 0
+	for _, pos := range path[1:] {
+		cost += level.TileAtPos(pos).Cost
+	}
+	return cost
+}
+
+// bruteForceCost is the cheapest way from from to to, found by relaxing every tile until nothing changes
+func bruteForceCost(level *Level, from, to Pos) int {
+	cost := map[Pos]int{from: 0}
+	for changed := true; changed; {
+		changed = false
+		for y := range level.Level {
+			for x := range level.Level[y] {
+				pos := Pos{x, y}
+				c, reached := cost[pos]
+				if !reached {
+					continue
+				}
+				for _, n := range getNeighbours(level, pos, canWalk) {
+					if old, seen := cost[n]; !seen || c+level.TileAtPos(n).Cost < old {
+						cost[n] = c + level.TileAtPos(n).Cost
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	if c, reached := cost[to]; reached {
+		return c
+	}
+	return Unreachable
+}
+
+// openRows is a w by h level with no walls at all
+func openRows(w, h int) []string {
+	rows := make([]string, h)
+	for y := range rows {
+		rows[y] = strings.Repeat(".", w)
+	}
+	return rows
+}
@@ -1,85 +1,98 @@
 package game
 
-type PriorityPos struct {
-	Pos
-	Priority int
+// PriorityQueue hands out whatever has the lowest priority first. It keeps track of where everything
+// is in the heap, so pushing something that's already queued moves it instead of adding it twice
+type PriorityQueue[T comparable] struct {
+	heap  []queued[T]
+	index map[T]int // where each item is in heap
 }
 
-type pqueue []priorityPos
-
-func (pq pqueue) push(pos Pos, priority int) pqueue {
-	newNode := priorityPos{pos, priority}
-	pq = append(pq, newNode)
-	newNodeIndex := len(pq) - 1
-	parentIndex, parent := pq.parent(newNodeIndex)
-
-	for newNode.priority < parent.priority && parentIndex != 0 {
-		pq.swap(newNodeIndex, parentIndex)
-		newNodeIndex = parentIndex
-		parentIndex, parent = pq.parent(newNodeIndex)
-
-	}
-
-	return pq
+type queued[T comparable] struct {
+	item     T
+	priority int
 }
 
-func (pq pqueue) pop() (pqueue, Pos) {
-	result := pq[0].Pos
-	pq[0] = pq[len(pq)-1]
-	pq = pq[:len(pq)-1]
-
-	if len(pq) == 0 {
-		return pq, result
-	}
-
-	index := 0
-	node := pq[index]
+func NewPriorityQueue[T comparable]() *PriorityQueue[T] {
+	return &PriorityQueue[T]{index: make(map[T]int)}
+}
 
-	leftChildExists, leftIndex, leftChild := pq.leftChild(index)
-	rightChildExists, rightIndex, rightChild := pq.rightChild(index)
+func (pq *PriorityQueue[T]) Len() int {
+	return len(pq.heap)
+}
 
-	for leftChildExists && node.priority > leftChild.priority ||
-		rightChildExists && node.priority > rightChild.priority {
+// Contains reports whether item is waiting in the queue
+func (pq *PriorityQueue[T]) Contains(item T) bool {
+	_, exists := pq.index[item]
+	return exists
+}
 
-		if !rightChildExists || leftChild.priority <= rightChild.priority {
-			pq.swap(index, leftIndex)
-			index = leftIndex
+// Push adds item, or gives it the new priority if it's already queued
+func (pq *PriorityQueue[T]) Push(item T, priority int) {
+	if i, exists := pq.index[item]; exists {
+		old := pq.heap[i].priority
+		pq.heap[i].priority = priority
+		if priority < old {
+			pq.up(i)
 		} else {
-			pq.swap(index, rightIndex)
-			index = rightIndex
+			pq.down(i)
 		}
-
-		leftChildExists, leftIndex, leftChild = pq.leftChild(index)
-		rightChildExists, rightIndex, rightChild = pq.rightChild(index)
+		return
 	}
-
-	return pq, result
+	pq.heap = append(pq.heap, queued[T]{item, priority})
+	pq.index[item] = len(pq.heap) - 1
+	pq.up(len(pq.heap) - 1)
 }
 
-func (pq pqueue) swap(i, j int) {
-	tmp := pq[i]
-	pq[i] = pq[j]
-	pq[j] = tmp
-
+// Pop takes out the item with the lowest priority. The queue mustn't be empty
+func (pq *PriorityQueue[T]) Pop() (T, int) {
+	top := pq.heap[0]
+	last := len(pq.heap) - 1
+	delete(pq.index, top.item)
+	if last > 0 {
+		pq.heap[0] = pq.heap[last]
+		pq.heap = pq.heap[:last]
+		pq.down(0)
+	} else {
+		pq.heap = pq.heap[:0]
+	}
+	return top.item, top.priority
 }
 
-func (pq pqueue) parent(i int) (int, priorityPos) {
-	index := (i-1)/2
-	return index, pq[index]
+// up and down slide the entry at i into place, moving the ones in the way instead of swapping
+// so the index only gets written once for each entry that moves
+func (pq *PriorityQueue[T]) up(i int) {
+	entry := pq.heap[i]
+	for i > 0 {
+		parent := (i - 1) / 2
+		if pq.heap[parent].priority <= entry.priority {
+			break
+		}
+		pq.set(i, pq.heap[parent])
+		i = parent
+	}
+	pq.set(i, entry)
 }
 
-func (pq pqueue) leftChild(i int) (bool, int, priorityPos) {
-	index := i*2+1
-	if index < len(pq) {
-		return true, index, pq[index]
+func (pq *PriorityQueue[T]) down(i int) {
+	entry := pq.heap[i]
+	for {
+		child := 2*i + 1
+		if child >= len(pq.heap) {
+			break
+		}
+		if right := child + 1; right < len(pq.heap) && pq.heap[right].priority < pq.heap[child].priority {
+			child = right
+		}
+		if entry.priority <= pq.heap[child].priority {
+			break
+		}
+		pq.set(i, pq.heap[child])
+		i = child
 	}
-	return false, 0, priorityPos{}
+	pq.set(i, entry)
 }
 
-func (pq pqueue) rightChild(i int) (bool, int, priorityPos) {
-	index := i*2+2
-	if index < len(pq) {
-		return true, index, pq[index]
-	}
-	return false, 0, priorityPos{}
-}
\ No newline at end of file
+func (pq *PriorityQueue[T]) set(i int, entry queued[T]) {
+	pq.heap[i] = entry
+	pq.index[entry.item] = i
+}
@@ -0,0 +1,129 @@
+package game
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkHeap fails the test if any entry is below its parent or the index is out of step with the heap
+func checkHeap[T comparable](t *testing.T, pq *PriorityQueue[T]) {
+	t.Helper()
+	if len(pq.index) != len(pq.heap) {
+		t.Fatalf("index has %d items but the heap has %d", len(pq.index), len(pq.heap))
+	}
+	for i, entry := range pq.heap {
+		if pq.index[entry.item] != i {
+			t.Fatalf("index says %v is at %d but it's at %d", entry.item, pq.index[entry.item], i)
+		}
+		if parent := (i - 1) / 2; i > 0 && pq.heap[parent].priority > entry.priority {
+			t.Fatalf("%v (%d) is below %v (%d)", entry.item, entry.priority, pq.heap[parent].item, pq.heap[parent].priority)
+		}
+	}
+}
+
+func TestPriorityQueueRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 100; run++ {
+		pq := NewPriorityQueue[int]()
+		want := make(map[int]int)
+		for i := 0; i < 200; i++ {
+			item := r.Intn(300)
+			if r.Intn(4) == 0 && pq.Len() > 0 {
+				got, priority := pq.Pop()
+				if priority != want[got] {
+					t.Fatalf("popped %d with priority %d, it was pushed with %d", got, priority, want[got])
+				}
+				for other, p := range want {
+					if p < priority {
+						t.Fatalf("popped %d (%d) while %d (%d) was still queued", got, priority, other, p)
+					}
+				}
+				delete(want, got)
+			} else {
+				priority := r.Intn(100) - 50
+				pq.Push(item, priority)
+				want[item] = priority
+			}
+			checkHeap(t, pq)
+		}
+		if pq.Len() != len(want) {
+			t.Fatalf("queue has %d items, want %d", pq.Len(), len(want))
+		}
+	}
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	pq := NewPriorityQueue[Pos]()
+	var priorities []int
+	for i := 0; i < 500; i++ {
+		p := r.Intn(1000)
+		pq.Push(Pos{i, 0}, p)
+		priorities = append(priorities, p)
+	}
+	sort.Ints(priorities)
+	for i, want := range priorities {
+		if _, got := pq.Pop(); got != want {
+			t.Fatalf("pop %d had priority %d, want %d", i, got, want)
+		}
+	}
+	if pq.Len() != 0 {
+		t.Errorf("%d left after popping everything", pq.Len())
+	}
+}
+
+func TestPriorityQueueChangeKey(t *testing.T) {
+	pq := NewPriorityQueue[string]()
+	for i, item := range []string{"a", "b", "c", "d", "e"} {
+		pq.Push(item, (i+1)*10)
+	}
+
+	pq.Push("e", 5) // decrease-key
+	checkHeap(t, pq)
+	pq.Push("a", 45) // increase-key
+	checkHeap(t, pq)
+	if pq.Len() != 5 {
+		t.Fatalf("pushing queued items again added them, %d in the queue", pq.Len())
+	}
+	if !pq.Contains("a") || pq.Contains("z") {
+		t.Error("Contains is wrong")
+	}
+
+	want := []string{"e", "b", "c", "d", "a"}
+	for _, w := range want {
+		if got, _ := pq.Pop(); got != w {
+			t.Fatalf("popped %s, want %s", got, w)
+		}
+		checkHeap(t, pq)
+	}
+	if pq.Contains("a") {
+		t.Error("popped items are still in the queue")
+	}
+}
+
+// openLevel is a size by size room with a wall most of the way down the middle so paths have to go round
+func openLevel(size int) *Level {
+	level := &Level{Level: make([][]Tile, size), Monsters: make(map[Pos]*Monster)}
+	level.LoadTileMap()
+	for y := range level.Level {
+		level.Level[y] = make([]Tile, size)
+		for x := range level.Level[y] {
+			level.Level[y][x] = level.TileMap[DirtFloor]
+			if x == size/2 && y < size*9/10 {
+				level.Level[y][x] = level.TileMap[StoneWall]
+			}
+		}
+	}
+	return level
+}
+
+func BenchmarkFindPath100x100(b *testing.B) {
+	level := openLevel(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, found := level.findPath(Pos{1, 1}, Pos{98, 1}, canWalk); !found {
+			b.Fatal("no path")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package game
+
+import "math/rand"
+
+// countingSource wraps the default source and counts how many values were drawn from it.
+// math/rand doesn't let you read the state back out, so saving the seed and the count
+// is enough to put a level's R back exactly where it was
+type countingSource struct {
+	seed  int64
+	calls uint64
+	src   rand.Source64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{seed: seed, src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.calls++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.calls++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.seed = seed
+	s.calls = 0
+	s.src.Seed(seed)
+}
+
+// restore reseeds the source and throws away values until it is back at the same spot
+func (s *countingSource) restore(seed int64, calls uint64) {
+	s.Seed(seed)
+	for s.calls < calls {
+		s.Int63()
+	}
+}
+
+// seedRandom gives the level its own deterministic random source
+func (level *Level) seedRandom(seed int64) {
+	level.src = newCountingSource(seed)
+	level.R = rand.New(level.src)
+}
@@ -0,0 +1,280 @@
+package game
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// ReplayVersion is bumped whenever the replay file layout changes
+const ReplayVersion = 2
+
+// A replay file is json lines: a replayHeader, one replayInput per input and a final QuitGame
+// replayInput holding the hash of the game state when recording stopped
+
+type replayHeader struct {
+	Version  int
+	Dungeon  DungeonParams
+	Levels   []string
+	Corners  CornerRule
+	Monsters string   // MonsterFile
+	Packs    []string // name and version of each pack in load order
+	Content  string   // contentHash, catches a different -content directory or changed files
+}
+
+type replayInput struct {
+	Type      InputType
+	MapPos    Pos
+	ItemIndex int    `json:",omitempty"`
+	Hash      string `json:",omitempty"`
+}
+
+// Recorder writes every input Run handles to a replay file
+type Recorder struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	err  error // the first write that failed, nothing more is written after it
+}
+
+// Record starts writing the inputs of this game to path. Call it before Run
+func (g *Game) Record(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	content, err := contentHash()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w := bufio.NewWriter(file)
+	r := &Recorder{file: file, w: w, enc: json.NewEncoder(w)}
+	err = r.enc.Encode(replayHeader{ReplayVersion, g.Dungeon, g.levelNames(), Corners, MonsterFile, packNames(), content})
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	g.recorder = r
+	return nil
+}
+
+// record writes input to the replay. A failed write doesn't stop the game, stopRecording reports it
+func (g *Game) record(input *Input) {
+	r := g.recorder
+	if r == nil || r.err != nil {
+		return
+	}
+	err := r.enc.Encode(replayInput{Type: input.Type, MapPos: *input.MapPos, ItemIndex: input.ItemIndex})
+	if err != nil {
+		r.err = fmt.Errorf("replay %s: %w", r.file.Name(), err)
+	}
+}
+
+// stopRecording finishes the replay off with the hash of the final state
+func (g *Game) stopRecording() error {
+	r := g.recorder
+	if r == nil {
+		return nil
+	}
+	g.recorder = nil
+
+	if r.err != nil {
+		r.file.Close()
+		return r.err
+	}
+	hash, err := g.StateHash()
+	if err == nil {
+		err = r.enc.Encode(replayInput{Type: QuitGame, Hash: hash})
+	}
+	if err == nil {
+		err = r.w.Flush()
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("replay %s: %w", r.file.Name(), err)
+	}
+	return nil
+}
+
+// StateHash is a sha256 of everything that goes in a save file, two games with the same hash are the same
+func (g *Game) StateHash() (string, error) {
+	data, err := g.marshalSave()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// contentHash is a sha256 of the maps and data files in Content and in every loaded pack, they all
+// change how a game plays out
+func contentHash() (string, error) {
+	h := sha256.New()
+	add := func(fsys fs.FS, dir string) error {
+		err := fs.WalkDir(fsys, dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := fs.ReadFile(fsys, path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %d\n", path, len(data))
+			h.Write(data)
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	for _, dir := range []string{"maps", "data"} {
+		if err := add(Content, dir); err != nil {
+			return "", err
+		}
+	}
+	for _, p := range Packs {
+		for _, dir := range []string{"maps", "data"} {
+			if err := add(p.FS, p.Path(dir)); err != nil {
+				return "", err
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func packNames() []string {
+	names := make([]string, 0, len(Packs))
+	for _, p := range Packs {
+		names = append(names, p.String())
+	}
+	return names
+}
+
+type Replay struct {
+	Dungeon  DungeonParams
+	Levels   []string
+	Corners  CornerRule
+	Monsters string
+	Packs    []string
+	Content  string
+	Inputs   []*Input
+	Hash     string // state hash at the end of the recording
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(file))
+	var header replayHeader
+	err = dec.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if header.Version != ReplayVersion {
+		return nil, fmt.Errorf("replay %s: version %d, expected %d", path, header.Version, ReplayVersion)
+	}
+
+	replay := &Replay{
+		Dungeon:  header.Dungeon,
+		Levels:   header.Levels,
+		Corners:  header.Corners,
+		Monsters: header.Monsters,
+		Packs:    header.Packs,
+		Content:  header.Content,
+	}
+	for {
+		var line replayInput
+		err = dec.Decode(&line)
+		if err != nil {
+			return nil, fmt.Errorf("replay %s: input %d: %w", path, len(replay.Inputs)+1, err)
+		}
+		if line.Type == QuitGame {
+			replay.Hash = line.Hash
+			break
+		}
+		mapPos := line.MapPos
+		replay.Inputs = append(replay.Inputs, &Input{Type: line.Type, MapPos: &mapPos, ItemIndex: line.ItemIndex})
+	}
+
+	return replay, nil
+}
+
+// NewGame makes a game with the same levels, seed and rules the replay was recorded with. The
+// monsters, packs and content have to be loaded already and be the same as when it was recorded
+func (r *Replay) NewGame(numWindows int) (*Game, error) {
+	if MonsterFile != r.Monsters {
+		return nil, fmt.Errorf("replay was recorded with the monsters in %s but the ones in %s are loaded", r.Monsters, MonsterFile)
+	}
+	if packs := packNames(); fmt.Sprint(packs) != fmt.Sprint(r.Packs) {
+		return nil, fmt.Errorf("replay was recorded with the packs %v but %v are loaded", r.Packs, packs)
+	}
+	content, err := contentHash()
+	if err != nil {
+		return nil, err
+	}
+	if content != r.Content {
+		return nil, fmt.Errorf("replay was recorded with different maps or data files than the ones loaded, check the content directory and packs are the same")
+	}
+
+	Corners = r.Corners
+	g, err := NewGame(numWindows, r.Dungeon)
+	if err != nil {
+		return nil, err
+	}
+
+	names := g.levelNames()
+	if fmt.Sprint(names) != fmt.Sprint(r.Levels) {
+		return nil, fmt.Errorf("replay was recorded with levels %v but the game has %v", r.Levels, names)
+	}
+	return g, nil
+}
+
+// Play runs the game and feeds it the recorded inputs, waiting delay between each one. A delay of 0
+// plays it back as fast as possible. It returns an error if the game doesn't end up in the recorded state
+func (r *Replay) Play(g *Game, delay time.Duration) error {
+	g.replaying = true
+	done := make(chan bool)
+	go func() {
+		// nothing is recorded while replaying so Run has no error to give back
+		g.Run()
+		done <- true
+	}()
+
+	for i, input := range r.Inputs {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		select {
+		case g.InputChan <- input:
+		case <-done:
+			// every window was closed before the replay was over
+			return fmt.Errorf("replay stopped after %d of %d inputs", i, len(r.Inputs))
+		}
+	}
+	g.InputChan <- &Input{Type: QuitGame}
+	<-done
+
+	hash, err := g.StateHash()
+	if err != nil {
+		return err
+	}
+	if hash != r.Hash {
+		return fmt.Errorf("replay ended in state %s but %s was recorded", hash, r.Hash)
+	}
+	return nil
+}
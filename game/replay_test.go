@@ -0,0 +1,126 @@
+package game
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReplayIgnoresQuickSaves(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	path := filepath.Join(dir, "test.replay")
+	g := newTestGame(t, 0)
+	if err := g.Record(path); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() {
+		g.Run()
+		done <- true
+	}()
+	for _, input := range []InputType{Right, QuickSave, Down, QuickLoad, Left} {
+		g.InputChan <- &Input{Type: input}
+	}
+	g.InputChan <- &Input{Type: QuitGame}
+	<-done
+
+	if _, err := os.Stat(QuickSavePath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("recording wrote %s", QuickSavePath)
+	}
+	turnedOff := 0
+	for _, e := range g.Events.Events {
+		if e.Text == "saving and loading are turned off while recording or replaying" {
+			turnedOff++
+		}
+	}
+	if turnedOff != 2 {
+		t.Errorf("expected 2 warnings about saving, got %d", turnedOff)
+	}
+
+	// a quicksave lying around mustn't change how the replay plays out or get overwritten
+	if err := os.WriteFile(QuickSavePath, []byte("not a save"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	played, err := replay.NewGame(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replay.Play(played, 0); err != nil {
+		t.Error(err)
+	}
+	if data, _ := os.ReadFile(QuickSavePath); string(data) != "not a save" {
+		t.Errorf("playing the replay overwrote %s", QuickSavePath)
+	}
+}
+
+func TestReplayNeedsTheSameContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.replay")
+	g := newTestGame(t, 0)
+	if err := g.Record(path); err != nil {
+		t.Fatal(err)
+	}
+	playFor(g, Right, Down)
+	replay, err := LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replay.NewGame(0); err != nil {
+		t.Fatalf("nothing changed but got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"monster file", func() { MonsterFile = "data/bats.txt" }, "monsters in data/monsters.txt"},
+		{"packs", func() { Packs = []*Pack{{Name: "caves", Version: "1", FS: fstest.MapFS{}, Dir: "caves"}} }, "packs [] but [caves 1]"},
+		{"content", func() {
+			Content = Overlay(fstest.MapFS{"maps/level1.map": {Data: []byte("#")}}, Content)
+		}, "different maps or data files"},
+	}
+	for _, tt := range tests {
+		monsters, packs, content := MonsterFile, Packs, Content
+		tt.change()
+		_, err := replay.NewGame(0)
+		MonsterFile, Packs, Content = monsters, packs, content
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error about %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRunReturnsRecordingErrors(t *testing.T) {
+	g := newTestGame(t, 0)
+	if err := g.Record(filepath.Join(t.TempDir(), "test.replay")); err != nil {
+		t.Fatal(err)
+	}
+	// the file going away under the recorder makes every write fail
+	g.recorder.file.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- g.Run()
+	}()
+	g.InputChan <- &Input{Type: QuitGame}
+	if err := <-done; err == nil {
+		t.Error("Run didn't say writing the replay failed")
+	}
+}
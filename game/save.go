@@ -0,0 +1,231 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SaveVersion is bumped whenever the layout of saveFile changes
+const SaveVersion = 6
+
+const QuickSavePath = "quicksave.json"
+
+type saveFile struct {
+	Version      int
+	Turn         int
+	CurrentLevel string
+	Player       Player
+	Weapon       int // index into the player inventory, -1 when nothing is equipped
+	Armor        int
+	Levels       []savedLevel
+	Log          []Event
+}
+
+type savedLevel struct {
+	Name      string
+	Tiles     [][]Tile
+	Monsters  []Monster
+	Items     []Item
+	Stairs    []savedStairs
+	Seed      int64
+	RandCalls uint64
+
+	Description string
+	Music       string
+	Ambient     int
+	Triggers    []Trigger
+}
+
+// savedStairs is a StairMap entry with the level pointer swapped out for its name
+type savedStairs struct {
+	Pos
+	Level string
+	To    Pos
+}
+
+// SaveGame writes every level, the player and the random state of each level to path
+func (g *Game) SaveGame(path string) error {
+	data, err := g.marshalSave()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func (g *Game) marshalSave() ([]byte, error) {
+	if g.CurrentLevel == nil {
+		return nil, fmt.Errorf("save: no current level")
+	}
+
+	save := saveFile{
+		Version:      SaveVersion,
+		Turn:         g.Scheduler.Turn,
+		CurrentLevel: g.CurrentLevel.Name,
+		Player:       *g.CurrentLevel.Player,
+		Weapon:       -1,
+		Armor:        -1,
+		Log:          g.Events.Events,
+	}
+	for i, item := range save.Player.Inventory {
+		if item == save.Player.Weapon {
+			save.Weapon = i
+		}
+		if item == save.Player.Armor {
+			save.Armor = i
+		}
+	}
+
+	for _, name := range g.levelNames() {
+		level := g.Levels[name]
+		saved := savedLevel{
+			Name:      name,
+			Tiles:     level.Level,
+			Seed:      level.src.seed,
+			RandCalls: level.src.calls,
+
+			Description: level.Description,
+			Music:       level.Music,
+			Ambient:     level.Ambient,
+		}
+		for _, t := range level.Triggers {
+			saved.Triggers = append(saved.Triggers, *t)
+		}
+
+		monsterPositions := make([]Pos, 0, len(level.Monsters))
+		for pos := range level.Monsters {
+			monsterPositions = append(monsterPositions, pos)
+		}
+		sortPositions(monsterPositions)
+		for _, pos := range monsterPositions {
+			saved.Monsters = append(saved.Monsters, *level.Monsters[pos])
+		}
+
+		itemPositions := make([]Pos, 0, len(level.Items))
+		for pos := range level.Items {
+			itemPositions = append(itemPositions, pos)
+		}
+		sortPositions(itemPositions)
+		for _, pos := range itemPositions {
+			for _, item := range level.Items[pos] {
+				saved.Items = append(saved.Items, *item)
+			}
+		}
+
+		stairPositions := make([]Pos, 0, len(level.StairMap))
+		for pos := range level.StairMap {
+			stairPositions = append(stairPositions, pos)
+		}
+		sortPositions(stairPositions)
+		for _, pos := range stairPositions {
+			stairs := level.StairMap[pos]
+			if stairs.Level == nil {
+				return nil, fmt.Errorf("save: stairs at %s in %s lead nowhere", pos.posToString(), name)
+			}
+			saved.Stairs = append(saved.Stairs, savedStairs{pos, stairs.Level.Name, stairs.Pos})
+		}
+
+		save.Levels = append(save.Levels, saved)
+	}
+
+	return json.Marshal(save)
+}
+
+// LoadGame replaces the levels of the game with the ones stored in path.
+// The channels are left alone so any open windows keep working
+func (g *Game) LoadGame(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var save saveFile
+	err = json.Unmarshal(data, &save)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", path, err)
+	}
+	if save.Version != SaveVersion {
+		return fmt.Errorf("load %s: save version %d, expected %d", path, save.Version, SaveVersion)
+	}
+
+	player := &Player{}
+	*player = save.Player
+	if save.Weapon >= 0 && save.Weapon < len(player.Inventory) {
+		player.Weapon = player.Inventory[save.Weapon]
+	}
+	if save.Armor >= 0 && save.Armor < len(player.Inventory) {
+		player.Armor = player.Inventory[save.Armor]
+	}
+
+	levels := make(map[string]*Level)
+	for _, saved := range save.Levels {
+		level := &Level{}
+		level.Name = saved.Name
+		level.Level = saved.Tiles
+		level.Player = player
+		level.Description = saved.Description
+		level.Music = saved.Music
+		level.Ambient = saved.Ambient
+		for i := range saved.Triggers {
+			level.Triggers = append(level.Triggers, &saved.Triggers[i])
+		}
+		level.Debug = make(map[Pos]bool)
+		level.StairMap = make(map[Pos]*LevelPos)
+		level.LoadTileMap()
+		level.seedRandom(saved.Seed)
+		level.src.restore(saved.Seed, saved.RandCalls)
+
+		level.Monsters = make(map[Pos]*Monster)
+		for i := range saved.Monsters {
+			m := saved.Monsters[i]
+			level.Monsters[m.Pos] = &m
+		}
+
+		level.Items = make(map[Pos][]*Item)
+		for i := range saved.Items {
+			item := saved.Items[i]
+			level.addItem(&item)
+		}
+
+		levels[saved.Name] = level
+	}
+
+	// second pass once every level exists so the stairs can point at them
+	for _, saved := range save.Levels {
+		level := levels[saved.Name]
+		for _, stairs := range saved.Stairs {
+			to := levels[stairs.Level]
+			if to == nil {
+				return fmt.Errorf("load %s: stairs in %s lead to unknown level %s", path, saved.Name, stairs.Level)
+			}
+			level.StairMap[stairs.Pos] = &LevelPos{to, stairs.To}
+		}
+	}
+
+	current := levels[save.CurrentLevel]
+	if current == nil {
+		return fmt.Errorf("load %s: unknown current level %s", path, save.CurrentLevel)
+	}
+
+	g.Levels = levels
+	g.CurrentLevel = current
+	g.Scheduler.Turn = save.Turn
+	// the log itself stays so anything subscribed to it keeps getting events
+	g.Events.Events = save.Log
+	g.Events.Turn = save.Turn
+	g.shareEvents()
+
+	return nil
+}
+
+// levelNames returns the level names in a fixed order so saves come out the same every time
+func (g *Game) levelNames() []string {
+	names := make([]string, 0, len(g.Levels))
+	for name := range g.Levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// playFor runs g through inputs and quits
+func playFor(g *Game, inputs ...InputType) {
+	done := make(chan bool)
+	go func() {
+		g.Run()
+		done <- true
+	}()
+	for _, input := range inputs {
+		g.InputChan <- &Input{Type: input}
+	}
+	g.InputChan <- &Input{Type: QuitGame}
+	<-done
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	g := newTestGame(t, 0)
+	g.CurrentLevel.Player.Inventory = append(g.CurrentLevel.Player.Inventory, NewSword(Pos{}))
+	g.CurrentLevel.Player.Weapon = g.CurrentLevel.Player.Inventory[0]
+	playFor(g, Right, Right, Down, Down, Left, Up)
+
+	path := filepath.Join(t.TempDir(), "save.json")
+	if err := g.SaveGame(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newTestGame(t, 0)
+	if err := loaded.LoadGame(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.CurrentLevel.Name != g.CurrentLevel.Name || loaded.Scheduler.Turn != g.Scheduler.Turn {
+		t.Errorf("loaded %s on turn %d, saved %s on turn %d", loaded.CurrentLevel.Name, loaded.Scheduler.Turn, g.CurrentLevel.Name, g.Scheduler.Turn)
+	}
+	if !reflect.DeepEqual(*loaded.CurrentLevel.Player, *g.CurrentLevel.Player) {
+		t.Errorf("player is %+v, saved %+v", *loaded.CurrentLevel.Player, *g.CurrentLevel.Player)
+	}
+	p := loaded.CurrentLevel.Player
+	if p.Weapon != p.Inventory[0] {
+		t.Error("the weapon isn't the sword in the inventory any more")
+	}
+	if !reflect.DeepEqual(loaded.Events.Events, g.Events.Events) || loaded.Events.Turn != g.Events.Turn {
+		t.Errorf("event log is %v, saved %v", loaded.Events.Events, g.Events.Events)
+	}
+
+	for _, name := range g.levelNames() {
+		saved, level := g.Levels[name], loaded.Levels[name]
+		if level == nil {
+			t.Fatalf("%s is missing", name)
+		}
+		// the tiles hold the fog of war, Seen and Visible
+		if !reflect.DeepEqual(level.Level, saved.Level) {
+			t.Errorf("%s: tiles changed", name)
+		}
+		if len(level.Monsters) != len(saved.Monsters) {
+			t.Errorf("%s: %d monsters, saved %d", name, len(level.Monsters), len(saved.Monsters))
+		}
+		for pos, m := range saved.Monsters {
+			got, exists := level.Monsters[pos]
+			if !exists {
+				t.Errorf("%s: no monster at %v", name, pos)
+				continue
+			}
+			// seesPlayer is worked out again on the monster's next turn
+			want := *m
+			want.seesPlayer = false
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("%s: monster at %v is %+v, saved %+v", name, pos, *got, want)
+			}
+		}
+		if level.Player != p {
+			t.Errorf("%s doesn't point at the loaded player", name)
+		}
+		if level.src.seed != saved.src.seed || level.src.calls != saved.src.calls {
+			t.Errorf("%s: random source at %d/%d, saved %d/%d", name, level.src.seed, level.src.calls, saved.src.seed, saved.src.calls)
+		}
+		for i := 0; i < 5; i++ {
+			if got, want := level.R.Int63(), saved.R.Int63(); got != want {
+				t.Errorf("%s: random number %d is %d, want %d", name, i, got, want)
+			}
+		}
+	}
+	if g.CurrentLevel.src.calls == 0 {
+		t.Error("nothing used the random numbers, the test doesn't show they're restored")
+	}
+}
+
+func TestQuickSaveFailureIsAnEvent(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	// a directory in the way of the quicksave so writing it fails
+	if err := os.Mkdir(QuickSavePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newTestGame(t, 0)
+	playFor(g, QuickSave, QuickLoad)
+	var warnings []string
+	for _, e := range g.Events.Events {
+		if e.Severity == Warning {
+			warnings = append(warnings, e.Text)
+		}
+	}
+	if len(warnings) != 2 || !strings.HasPrefix(warnings[0], "couldn't save the game: ") || !strings.HasPrefix(warnings[1], "couldn't load the game: ") {
+		t.Errorf("got warnings %q", warnings)
+	}
+}
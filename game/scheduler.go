@@ -0,0 +1,69 @@
+package game
+
+import "sort"
+
+// ActionThreshold is how many AP an actor needs before it gets to act. Actions can take an actor
+// below zero, a slow action like wading through water just means a longer wait for the next one
+const ActionThreshold = 1.0
+
+// Scheduler owns the AP of the player and every monster on the current level.
+// Each tick every actor gains its Speed in AP and then monsters with enough AP act
+type Scheduler struct {
+	Turn int
+}
+
+// Tick moves the world forward by one turn
+func (s *Scheduler) Tick(level *Level) {
+	s.Turn++
+	level.Events.Turn = s.Turn
+	level.huntMaps = nil
+	level.fleeMaps = nil
+	level.Player.AP += level.Player.Speed
+
+	monsters := level.monstersInOrder()
+	for _, m := range monsters {
+		m.AP += m.Speed
+	}
+
+	// whoever has saved up the most goes first, positions break ties so it's the same every run
+	sort.SliceStable(monsters, func(i, j int) bool {
+		return monsters[i].AP > monsters[j].AP
+	})
+
+	for _, m := range monsters {
+		for m.AP >= ActionThreshold && level.Monsters[m.Pos] == m {
+			ap := m.AP
+			m.Update(level)
+			if m.AP == ap {
+				// nothing to do still costs the turn, otherwise idle monsters would bank AP forever
+				m.AP -= ActionThreshold
+			}
+		}
+	}
+}
+
+// Advance ticks until the player is allowed to act again
+func (s *Scheduler) Advance(level *Level) {
+	p := level.Player
+	if p.Speed <= 0 {
+		return
+	}
+	for p.Alive && p.AP < ActionThreshold {
+		s.Tick(level)
+	}
+}
+
+// monstersInOrder returns the monsters sorted by position since map order is random
+func (level *Level) monstersInOrder() []*Monster {
+	positions := make([]Pos, 0, len(level.Monsters))
+	for pos := range level.Monsters {
+		positions = append(positions, pos)
+	}
+	sortPositions(positions)
+
+	monsters := make([]*Monster, 0, len(positions))
+	for _, pos := range positions {
+		monsters = append(monsters, level.Monsters[pos])
+	}
+	return monsters
+}
@@ -0,0 +1,103 @@
+package game
+
+import "testing"
+
+// countingBehavior does nothing but count how often each monster acts, a turn costs one AP
+type countingBehavior map[string]int
+
+func (c countingBehavior) Act(m *Monster, level *Level) {
+	c[m.Name]++
+	m.AP -= ActionThreshold
+}
+
+func testPlayer(pos Pos, speed float64) *Player {
+	return &Player{Character: Character{Entity: Entity{Pos: pos, Name: "Player"}, Speed: speed, SightRange: 5, Hitpoints: 10, Alive: true}}
+}
+
+func testMonster(level *Level, name string, pos Pos, speed float64, behavior string) *Monster {
+	m := &Monster{Character: Character{Entity: Entity{Pos: pos, Name: name}, Speed: speed, Hitpoints: 10, Alive: true}, Behavior: behavior}
+	level.Monsters[pos] = m
+	return m
+}
+
+func TestSchedulerSpeeds(t *testing.T) {
+	acts := countingBehavior{}
+	RegisterBehavior("counting", acts)
+	defer delete(monsterBehaviors, "counting")
+
+	tests := []struct {
+		player             float64
+		slow, fast         float64
+		wantSlow, wantFast int // acts while the player acts 100 times
+		wantTurns          int
+	}{
+		{1, 0.5, 2, 50, 200, 100},
+		{0.5, 0.5, 2, 100, 400, 200},
+		{2, 0.5, 2, 25, 100, 50},
+	}
+	for _, tt := range tests {
+		level, m := fixture(t,
+			"#####",
+			"#PSF#",
+			"#####",
+		)
+		level.Events = NewEventLog()
+		level.Player = testPlayer(m['P'], tt.player)
+		testMonster(level, "slow", m['S'], tt.slow, "counting")
+		testMonster(level, "fast", m['F'], tt.fast, "counting")
+
+		// everyone starts on 0 AP, count from the player's first go
+		var s Scheduler
+		s.Advance(level)
+		start := s.Turn
+		for name := range acts {
+			delete(acts, name)
+		}
+		for i := 0; i < 100; i++ {
+			level.Player.AP -= ActionThreshold
+			s.Advance(level)
+		}
+		if acts["slow"] != tt.wantSlow || acts["fast"] != tt.wantFast || s.Turn-start != tt.wantTurns {
+			t.Errorf("player speed %v: slow acted %d times, fast %d times over %d turns, want %d, %d and %d",
+				tt.player, acts["slow"], acts["fast"], s.Turn-start, tt.wantSlow, tt.wantFast, tt.wantTurns)
+		}
+	}
+}
+
+func TestTileCostUsesAP(t *testing.T) {
+	level, m := fixture(t,
+		"######",
+		"#P~..#",
+		"#M~..#",
+		"######",
+	)
+	level.Events = NewEventLog()
+	level.Player = testPlayer(m['P'], 1)
+	level.Player.AP = ActionThreshold
+	monster := testMonster(level, "Rat", m['M'], 1, "")
+	monster.AP = ActionThreshold
+	g := &Game{CurrentLevel: level}
+	var s Scheduler
+
+	// water costs 2, so it's two turns before the player can go again
+	g.Move(level, Pos{2, 1})
+	if level.Player.AP != -1 {
+		t.Errorf("wading in left the player on %v AP, want -1", level.Player.AP)
+	}
+	s.Advance(level)
+	if s.Turn != 2 {
+		t.Errorf("waited %d turns after wading in, want 2", s.Turn)
+	}
+	g.Move(level, Pos{3, 1})
+	s.Advance(level)
+	if s.Turn != 3 {
+		t.Errorf("waited %d turns after stepping onto floor, want 1", s.Turn-2)
+	}
+
+	// monsters pay the same
+	monster.AP = ActionThreshold
+	monster.Move(Pos{2, 2}, level)
+	if monster.AP != -1 {
+		t.Errorf("the monster wading in is on %v AP, want -1", monster.AP)
+	}
+}
@@ -0,0 +1,216 @@
+package game
+
+import "time"
+
+// Travelling walks the player to a clicked tile one step at a time. Run makes up a TravelStep input
+// for every step so they get recorded like keys, when a replay is playing they come from the replay
+
+// TravelDelay is how long Run waits between travel steps so the walk can be watched
+var TravelDelay = 50 * time.Millisecond
+
+type travel struct {
+	path      []Pos
+	level     *Level
+	monsters  map[*Monster]bool // in view when the travel started, only new ones stop it
+	hitpoints int
+	explore   bool // keep going to the closest unexplored tile instead of stopping at the end of path
+
+	interrupted bool // something got logged, opening doors on the way doesn't count
+	unsubscribe func()
+}
+
+// listen watches the event log for anything that should stop the travel
+func (t *travel) listen(log *EventLog) {
+	t.unsubscribe = log.Subscribe(func(e Event) {
+		if e.Category != DoorEvent {
+			t.interrupted = true
+		}
+	})
+}
+
+// canTravel keeps travel paths on tiles the player knows about, closed doors get opened on the way
+func canTravel(level *Level, pos Pos) bool {
+	t := level.TileAtPos(pos)
+	if !t.Seen {
+		return false
+	}
+	return canWalk(level, pos) || t.Rune == ClosedDoor
+}
+
+// canTravelPast leaves out stairs, travel only takes them when they were clicked on
+func canTravelPast(level *Level, pos Pos) bool {
+	return canTravel(level, pos) && level.StairMap[pos] == nil
+}
+
+// travelPath returns the steps from the player to pos, without the tile the player is on
+func (level *Level) travelPath(to Pos) []Pos {
+	if !inRange(level, to) || !canTravel(level, to) {
+		return nil
+	}
+	walkable := func(level *Level, pos Pos) bool {
+		return pos == to || canTravelPast(level, pos)
+	}
+	path, _, found := level.findPath(level.Player.Pos, to, walkable)
+	if !found || len(path) < 2 {
+		return nil
+	}
+	return path[1:]
+}
+
+func (level *Level) visibleMonsters() map[*Monster]bool {
+	visible := make(map[*Monster]bool)
+	for _, m := range level.Monsters {
+		if level.TileAtPos(m.Pos).Visible {
+			visible[m] = true
+		}
+	}
+	return visible
+}
+
+func (g *Game) startTravel(to Pos) {
+	level := g.CurrentLevel
+	path := level.travelPath(to)
+	if path == nil {
+		return
+	}
+	g.travel = &travel{
+		path:      path,
+		level:     level,
+		monsters:  level.visibleMonsters(),
+		hitpoints: level.Player.Hitpoints,
+	}
+	g.travel.listen(level.Events)
+	level.Path = path
+}
+
+// unexplored is true for tiles next to one the player hasn't seen yet
+func (level *Level) unexplored(pos Pos) bool {
+	for _, d := range directions {
+		next := Pos{pos.X + d.X, pos.Y + d.Y}
+		if inRange(level, next) && !level.TileAtPos(next).Seen {
+			return true
+		}
+	}
+	return false
+}
+
+// explorePath is the way to the closest tile the player can get to that has something unexplored next to it
+func (level *Level) explorePath() []Pos {
+	target, found := level.bfsearch(level.Player.Pos, canTravelPast, level.unexplored)
+	if !found {
+		return nil
+	}
+	return level.travelPath(target)
+}
+
+// startExplore travels from one unexplored spot to the next until there are none left
+func (g *Game) startExplore() {
+	level := g.CurrentLevel
+	if len(level.visibleMonsters()) > 0 {
+		level.Events.Add(Event{Category: SystemEvent, Text: "not with monsters around"})
+		return
+	}
+	path := level.explorePath()
+	if path == nil {
+		level.Events.Add(Event{Category: SystemEvent, Text: "nothing left to explore"})
+		return
+	}
+	g.travel = &travel{
+		path:      path,
+		level:     level,
+		monsters:  map[*Monster]bool{},
+		hitpoints: level.Player.Hitpoints,
+		explore:   true,
+	}
+	g.travel.listen(level.Events)
+	level.Path = path
+}
+
+func (g *Game) stopTravel() {
+	if g.travel != nil {
+		g.travel.unsubscribe()
+		g.travel.level.Path = nil
+		g.travel = nil
+	}
+	g.CurrentLevel.Path = nil
+}
+
+// travelStep takes the next step, a closed door in the way takes one step to open and one to go through
+func (g *Game) travelStep() {
+	if g.travel == nil {
+		return
+	}
+	level := g.CurrentLevel
+	p := level.Player
+	next := g.travel.path[0]
+
+	if !canStep(level, p.Pos, next) || !canTravel(level, next) {
+		g.stopTravel()
+		return
+	}
+	if canWalk(level, next) {
+		g.Move(level, next)
+	} else {
+		p.Action(level, next)
+	}
+	if p.Pos == next {
+		g.travel.path = g.travel.path[1:]
+		level.Path = g.travel.path
+	}
+}
+
+// checkTravel stops travelling when the player got there or something worth looking at happened
+func (g *Game) checkTravel() {
+	t := g.travel
+	if t == nil {
+		return
+	}
+	level := g.CurrentLevel
+	p := level.Player
+	if level != t.level || !p.Alive || p.Hitpoints < t.hitpoints || t.interrupted {
+		g.stopTravel()
+		return
+	}
+	for m := range level.visibleMonsters() {
+		if !t.monsters[m] {
+			g.stopTravel()
+			return
+		}
+	}
+
+	if t.explore {
+		// what's closest changes as more of the level gets seen
+		t.path = level.explorePath()
+		level.Path = t.path
+		if t.path == nil {
+			g.stopTravel()
+			level.Events.Add(Event{Category: SystemEvent, Text: "nothing left to explore"})
+			return
+		}
+	}
+	if len(t.path) == 0 {
+		g.stopTravel()
+	}
+}
+
+// hover shows the path the player would take to pos, unless the player is already travelling
+func (g *Game) hover(pos Pos) {
+	if g.travel != nil || !g.CurrentLevel.Player.Alive {
+		return
+	}
+	g.CurrentLevel.Path = g.CurrentLevel.travelPath(pos)
+}
+
+// nextInput waits for the next input, while travelling it makes up a step if nothing else comes in
+func (g *Game) nextInput() (*Input, bool) {
+	if g.travel != nil && !g.replaying {
+		select {
+		case input, ok := <-g.InputChan:
+			return input, ok
+		case <-time.After(TravelDelay):
+			return &Input{Type: TravelStep}, true
+		}
+	}
+	input, ok := <-g.InputChan
+	return input, ok
+}
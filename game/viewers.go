@@ -0,0 +1,129 @@
+package game
+
+// Viewers are anything reading levels from Game.LevelChans, a window, a spectator or a recorder.
+// Run never waits on them: the channels hold one level and a viewer that falls behind only ever
+// gets the newest one. What they get is a snapshot, Run carries on changing the real level while
+// they draw so they must never see it
+
+// NewViewerChan makes a channel suitable for Game.LevelChans
+func NewViewerChan() chan *Level {
+	return make(chan *Level, 1)
+}
+
+// AddViewer registers a new viewer while the game is running and returns the channel it should read from
+func (g *Game) AddViewer() chan *Level {
+	c := NewViewerChan()
+	g.InputChan <- &Input{Type: OpenWindow, LevelChan: c}
+	return c
+}
+
+// RemoveViewer unregisters a viewer while the game is running, its channel gets closed
+func (g *Game) RemoveViewer(c chan *Level) {
+	g.InputChan <- &Input{Type: CloseWindow, LevelChan: c}
+}
+
+func (g *Game) addViewer(c chan *Level) {
+	for _, existing := range g.LevelChans {
+		if existing == c {
+			return
+		}
+	}
+	g.LevelChans = append(g.LevelChans, c)
+}
+
+func (g *Game) removeViewer(c chan *Level) {
+	for i, existing := range g.LevelChans {
+		if existing == c {
+			g.LevelChans = append(g.LevelChans[:i], g.LevelChans[i+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+// broadcast hands a snapshot of the current level to every viewer without blocking. If a viewer
+// hasn't picked up the last one yet it gets swapped for this one
+func (g *Game) broadcast() {
+	if len(g.LevelChans) == 0 {
+		return
+	}
+	// one snapshot is enough for everyone since nobody changes it
+	level := g.CurrentLevel.snapshot()
+	for _, c := range g.LevelChans {
+		select {
+		case c <- level:
+		default:
+			select {
+			case <-c:
+			default:
+			}
+			select {
+			case c <- level:
+			default:
+			}
+		}
+	}
+}
+
+// snapshot copies the parts of level the viewers draw: tiles, the player and its stats, monsters,
+// items, the travel path, debug marks and the message log. Nothing in it is shared with level apart
+// from events that are already in the log, those never change once they've been added
+func (level *Level) snapshot() *Level {
+	snap := &Level{
+		Name:        level.Name,
+		Description: level.Description,
+		Music:       level.Music,
+		Ambient:     level.Ambient,
+		Level:       make([][]Tile, len(level.Level)),
+		Monsters:    make(map[Pos]*Monster, len(level.Monsters)),
+		Items:       make(map[Pos][]*Item, len(level.Items)),
+		Debug:       make(map[Pos]bool, len(level.Debug)),
+		Path:        append([]Pos(nil), level.Path...),
+	}
+	for y, row := range level.Level {
+		snap.Level[y] = append([]Tile(nil), row...)
+	}
+
+	copied := make(map[*Item]*Item)
+	copyItem := func(item *Item) *Item {
+		if item == nil {
+			return nil
+		}
+		if c, exists := copied[item]; exists {
+			return c
+		}
+		c := *item
+		copied[item] = &c
+		return &c
+	}
+
+	p := *level.Player
+	p.Inventory = make([]*Item, len(level.Player.Inventory))
+	for i, item := range level.Player.Inventory {
+		p.Inventory[i] = copyItem(item)
+	}
+	p.Weapon = copyItem(p.Weapon)
+	p.Armor = copyItem(p.Armor)
+	snap.Player = &p
+
+	for pos, m := range level.Monsters {
+		c := *m
+		snap.Monsters[pos] = &c
+	}
+	for pos, items := range level.Items {
+		copies := make([]*Item, len(items))
+		for i, item := range items {
+			copies[i] = copyItem(item)
+		}
+		snap.Items[pos] = copies
+	}
+	for pos, debug := range level.Debug {
+		snap.Debug[pos] = debug
+	}
+
+	if level.Events != nil {
+		events := level.Events.Events
+		snap.Events = &EventLog{Events: events[:len(events):len(events)], Turn: level.Events.Turn}
+	}
+	return snap
+}
@@ -0,0 +1,91 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	g := newTestGame(t, 0)
+	g.start()
+	level := g.CurrentLevel
+	level.Player.Inventory = append(level.Player.Inventory, NewSword(level.Player.Pos))
+	level.Player.Weapon = level.Player.Inventory[0]
+	level.Path = []Pos{level.Player.Pos}
+
+	snap := level.snapshot()
+	events := len(snap.Events.Events)
+
+	level.Level[1][1].Rune = 'X'
+	level.Player.Hitpoints = -5
+	level.Player.Inventory[0].Power = 100
+	level.Path[0] = Pos{-1, -1}
+	for pos, m := range level.Monsters {
+		m.Hitpoints = -5
+		delete(level.Monsters, pos)
+	}
+	level.Events.Add(Event{Text: "after the snapshot"})
+
+	if snap.Level[1][1].Rune == 'X' {
+		t.Error("tiles are shared with the level")
+	}
+	if snap.Player.Hitpoints == -5 {
+		t.Error("the player is shared with the level")
+	}
+	if snap.Player.Inventory[0].Power == 100 || snap.Player.Weapon != snap.Player.Inventory[0] {
+		t.Error("items are shared with the level or the weapon isn't one of the copied items")
+	}
+	if snap.Path[0] == (Pos{-1, -1}) {
+		t.Error("the path is shared with the level")
+	}
+	if len(snap.Monsters) == 0 {
+		t.Error("monsters are shared with the level")
+	}
+	for _, m := range snap.Monsters {
+		if m.Hitpoints == -5 {
+			t.Error("a monster is shared with the level")
+		}
+	}
+	if len(snap.Events.Events) != events {
+		t.Error("events added later show up in the snapshot")
+	}
+}
+
+// run with -race, a viewer reads everything the UIs draw while Run explores the level on its own
+func TestViewerWhileExploring(t *testing.T) {
+	defer func(delay time.Duration) { TravelDelay = delay }(TravelDelay)
+	TravelDelay = time.Millisecond
+
+	g := newTestGame(t, 1)
+	viewer := g.LevelChans[0]
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for level := range viewer {
+			for _, row := range level.Level {
+				for _, tile := range row {
+					_ = tile.Visible
+				}
+			}
+			for pos, m := range level.Monsters {
+				_ = level.Items[pos]
+				_ = m.Hitpoints
+			}
+			_ = level.Player.GetStatStrings()
+			_ = level.Player.GetInventoryStrings()
+			_ = level.Events.Last(10, 0)
+			_ = len(level.Path)
+		}
+	}()
+
+	finished := make(chan struct{})
+	go func() {
+		g.Run()
+		close(finished)
+	}()
+	g.InputChan <- &Input{Type: Explore}
+	time.Sleep(200 * time.Millisecond)
+	g.RemoveViewer(viewer)
+	<-finished
+	<-done
+}
@@ -1,8 +1,5 @@
 module rpg-sdl
 
-go 1.16
+go 1.18
 
-require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/veandco/go-sdl2 v0.4.8
-)
+require github.com/veandco/go-sdl2 v0.4.8
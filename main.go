@@ -1,18 +1,172 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
 	"rpg-sdl/game"
 	"rpg-sdl/ui2d"
+	"rpg-sdl/uiterm"
 	"runtime"
+	"time"
 )
 
 func main() {
+	dungeon := game.DefaultDungeonParams()
+	frontEnd := flag.String("ui", "2d", "front end to use: 2d or term")
+	monsterFile := flag.String("monsters", game.DefaultMonsters, "file with the monster definitions, inside the content")
+	content := flag.String("content", "", "directory of maps/, data/ and assets/ files that replace or add to the built in ones")
+	packs := flag.String("packs", "", "directory of content packs, each in its own directory with a pack.txt")
+	spectators := flag.Int("spectators", 0, "number of extra windows that only watch the game (2d only)")
+	follow := flag.String("follow", "", "name of the monster spectator windows follow, like \"Spider 1\"")
+	record := flag.String("record", "", "write a replay of the game to this file")
+	replay := flag.String("replay", "", "play back a replay file and check it ends the way it was recorded")
+	replayDelay := flag.Duration("replay-delay", 100*time.Millisecond, "time between inputs when playing a replay, 0 plays it instantly without a window")
+	corners := flag.String("corners", "never", "when diagonal moves can squeeze past walls and closed doors: never, oneside or always")
+	flag.Int64Var(&dungeon.Seed, "seed", dungeon.Seed, "seed for all random numbers in the game")
+	flag.IntVar(&dungeon.Depth, "depth", dungeon.Depth, "number of generated levels below the hand made ones")
+	flag.IntVar(&dungeon.Width, "width", dungeon.Width, "width of generated levels")
+	flag.IntVar(&dungeon.Height, "height", dungeon.Height, "height of generated levels")
+	flag.Parse()
+
+	cornerRules := map[string]game.CornerRule{
+		"never":   game.CornersNever,
+		"oneside": game.CornersOneSide,
+		"always":  game.CornersAlways,
+	}
+	rule, ok := cornerRules[*corners]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown corner rule %q\n", *corners)
+		os.Exit(1)
+	}
+	game.Corners = rule
+
+	if *content != "" {
+		info, err := os.Stat(*content)
+		if err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "content directory %s not found\n", *content)
+			os.Exit(1)
+		}
+		mods := os.DirFS(*content)
+		game.Content = game.Overlay(mods, game.Content)
+		ui2d.Content = game.Overlay(mods, ui2d.Content)
+	}
+
+	err := game.LoadMonsterTypes(*monsterFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *packs != "" {
+		err = game.LoadPacks(os.DirFS(*packs))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
 	runtime.LockOSThread()
-	g := game.NewGame(1)
 
-	go func() {
-		g.Run()
-	}()
-	ui := ui2d.NewUI(g.InputChan, g.LevelChans[0])
-	ui.GetInput()
+	if *replay != "" {
+		playReplay(*replay, *replayDelay)
+		return
+	}
+
+	g, err := game.NewGame(1+*spectators, dungeon)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *record != "" {
+		err = g.Record(*record)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// wait for the game to stop after the ui is done so the replay gets written out
+	done := make(chan bool)
+	var runErr error
+
+	switch *frontEnd {
+	case "term":
+		ui, err := uiterm.NewUI(g.InputChan, g.LevelChans[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		go func() {
+			runErr = g.Run()
+			close(done)
+		}()
+		ui.GetInput()
+	default:
+		// windows first so a missing asset stops us before the game starts
+		ui, err := ui2d.NewUI(g.InputChan, g.LevelChans[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, levelChan := range g.LevelChans[1:] {
+			spectator, err := ui2d.NewSpectator(g.InputChan, levelChan, *follow)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			ui.AddSpectator(spectator)
+		}
+		go func() {
+			runErr = g.Run()
+			close(done)
+		}()
+		ui.GetInput()
+	}
+	<-done
+	if runErr != nil {
+		fmt.Fprintln(os.Stderr, runErr)
+		os.Exit(1)
+	}
+}
+
+// playReplay plays a replay back in a spectator window, or with no window at all when delay is 0,
+// and exits non-zero if the game ends up somewhere different than when it was recorded
+func playReplay(path string, delay time.Duration) {
+	replay, err := game.LoadReplay(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	windows := 1
+	if delay == 0 {
+		windows = 0
+	}
+	g, err := replay.NewGame(windows)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if delay == 0 {
+		err = replay.Play(g, 0)
+	} else {
+		result := make(chan error, 1)
+		go func() {
+			result <- replay.Play(g, delay)
+		}()
+		ui, err := ui2d.NewSpectator(g.InputChan, g.LevelChans[0], "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		ui.GetInput()
+		err = <-result
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("replay matches")
 }
@@ -2,32 +2,57 @@ package ui2d
 
 import (
 	"bufio"
+	"embed"
+	"fmt"
+	"io/fs"
 	"math/rand"
-	"os"
 	"rpg-sdl/game"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/veandco/go-sdl2/img"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
 )
 
+//go:embed assets
+var builtin embed.FS
+
+// Content is where the fonts, sprite sheets and their indexes come from, paths look like "assets/font.ttf".
+// main can put a directory on top of it with game.Overlay
+var Content fs.FS = builtin
+
 type ui struct {
 	winWidth        int
 	winHeight       int
 	window          *sdl.Window
 	renderer        *sdl.Renderer
 	textureAtlas    *sdl.Texture
+	itemAtlas       *sdl.Texture
+	fontData        []byte // the fonts read from this for as long as they're open
 	fontSmall       *ttf.Font
 	fontMedium      *ttf.Font
 	fontLarge       *ttf.Font
 	panelBackground *sdl.Texture
+	pathMarker      *sdl.Texture
 	textureIndex    map[rune][]sdl.Rect
+	sheets          map[rune]*sdl.Texture // content pack sprites are on their own sheet, everything else is on textureAtlas
+	itemIndex       map[rune][]sdl.Rect
 	centerX         int
 	centerY         int
 	levelChan       chan *game.Level
 	inputChan       chan *game.Input
+	windowID        uint32
+	offsetX         int
+	offsetY         int
+	spectator       bool   // spectators only watch, they see the whole level and send no game input
+	follow          string // name of the monster the camera follows, empty for the player
+	spectators      []*ui
+	level           *game.Level
+	hoverTile       game.Pos // map tile under the mouse last time it moved
+	logScroll       int      // how many events the log panel is scrolled back
 	r               *rand.Rand
 	strToTexSmall   map[string]*sdl.Texture
 	strToTexMedium  map[string]*sdl.Texture
@@ -42,7 +67,21 @@ const (
 	FontLarge
 )
 
-func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
+// NewSpectator opens an extra window that watches the game and follows the monster called follow,
+// or the player if follow is empty. Add it to the main window with AddSpectator so it gets events
+func NewSpectator(inputChan chan *game.Input, levelChan chan *game.Level, follow string) (*ui, error) {
+	ui, err := NewUI(inputChan, levelChan)
+	if err != nil {
+		return nil, err
+	}
+	ui.spectator = true
+	ui.follow = follow
+	ui.window.SetTitle("rpg-sdl spectator")
+	return ui, nil
+}
+
+// NewUI opens the game window and loads the fonts and textures, a broken index file comes back as an *AtlasError
+func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) (*ui, error) {
 	ui := &ui{}
 	ui.inputChan = inputChan
 	ui.levelChan = levelChan
@@ -56,53 +95,163 @@ func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) *ui {
 	sdl.LogSetAllPriority(sdl.LOG_PRIORITY_VERBOSE)
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	ui.window, err = sdl.CreateWindow("rpg-sdl", 200, 200,
 		int32(ui.winWidth), int32(ui.winHeight), sdl.WINDOW_SHOWN)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	ui.windowID, err = ui.window.GetID()
+	if err != nil {
+		return nil, err
 	}
 
 	ui.renderer, err = sdl.CreateRenderer(ui.window, -1, sdl.RENDERER_ACCELERATED)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "1")
 
 	if err := ttf.Init(); err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	ui.fontSmall, err = ttf.OpenFont("ui2d/assets/font.ttf", 16)
+	ui.fontData, err = fs.ReadFile(Content, "assets/font.ttf")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	ui.fontMedium, err = ttf.OpenFont("ui2d/assets/font.ttf", 24)
+	ui.fontSmall, err = openFont(ui.fontData, 16)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	ui.fontLarge, err = ttf.OpenFont("ui2d/assets/font.ttf", 32)
+	ui.fontMedium, err = openFont(ui.fontData, 24)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	ui.textureAtlas, err = img.LoadTexture(ui.renderer, "ui2d/assets/tiles.png")
+	ui.fontLarge, err = openFont(ui.fontData, 32)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	ui.textureAtlas, err = ui.loadTexture(Content, "assets/tiles.png")
+	if err != nil {
+		return nil, err
+	}
+	ui.textureIndex, err = loadTextureIndex(Content, "assets/atlas-index.txt", 32, 64)
+	if err != nil {
+		return nil, err
+	}
+	for r, mt := range game.MonsterTypes {
+		if mt.SpriteX >= 0 && mt.SpriteY >= 0 {
+			ui.textureIndex[r] = []sdl.Rect{{X: int32(mt.SpriteX * 32), Y: int32(mt.SpriteY * 32), W: 32, H: 32}}
+		}
+	}
+	err = ui.loadPackSprites()
+	if err != nil {
+		return nil, err
+	}
+
+	ui.itemAtlas, err = ui.loadTexture(Content, "assets/fongoose/RogueItems16x16.png")
+	if err != nil {
+		return nil, err
+	}
+	ui.itemIndex, err = loadTextureIndex(Content, "assets/item-index.txt", 16, 8)
+	if err != nil {
+		return nil, err
 	}
-	ui.loadTextureIndex()
 
 	ui.centerX = -1
 	ui.centerY = -1
 
 	ui.panelBackground = ui.GetSinglePixelTex(sdl.Color{0, 0, 0, 128})
 	ui.panelBackground.SetBlendMode(sdl.BLENDMODE_BLEND)
+	ui.pathMarker = ui.GetSinglePixelTex(sdl.Color{255, 220, 0, 96})
+	ui.pathMarker.SetBlendMode(sdl.BLENDMODE_BLEND)
 
-	return ui
+	return ui, nil
+}
+
+func openFont(data []byte, size int) (*ttf.Font, error) {
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+	return ttf.OpenFontRW(rw, 1, size)
+}
+
+func (ui *ui) loadTexture(fsys fs.FS, path string) (*sdl.Texture, error) {
+	data, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+	tex, err := img.LoadTextureRW(ui.renderer, rw, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tex, nil
+}
+
+// loadPackSprites adds the sprites of every content pack that has an atlas index. Packs can draw
+// over the built in sprites but two packs can't both have one for the same rune
+func (ui *ui) loadPackSprites() error {
+	ui.sheets = make(map[rune]*sdl.Texture)
+	owners := make(map[rune]*game.Pack)
+	conflicts := &game.PackError{}
+	for _, p := range game.Packs {
+		indexPath := p.Path("assets/atlas-index.txt")
+		if _, err := fs.Stat(p.FS, indexPath); err != nil {
+			continue
+		}
+		sheet, err := ui.loadTexture(p.FS, p.Path("assets/tiles.png"))
+		if err != nil {
+			return err
+		}
+		_, _, w, _, err := sheet.Query()
+		if err != nil {
+			return err
+		}
+		index, err := loadTextureIndex(p.FS, indexPath, 32, int64(w/32))
+		if err != nil {
+			return err
+		}
+
+		runes := make([]rune, 0, len(index))
+		for r := range index {
+			runes = append(runes, r)
+		}
+		sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+		for _, r := range runes {
+			if other, exists := owners[r]; exists {
+				conflicts.Problems = append(conflicts.Problems, fmt.Sprintf("%s: sprite for '%s' in %s is already in %s", indexPath, string(r), p, other))
+				continue
+			}
+			owners[r] = p
+			ui.textureIndex[r] = index[r]
+			ui.sheets[r] = sheet
+		}
+	}
+	if len(conflicts.Problems) > 0 {
+		return conflicts
+	}
+	return nil
+}
+
+// sheet is the texture the sprites for r are on
+func (ui *ui) sheet(r rune) *sdl.Texture {
+	if sheet, exists := ui.sheets[r]; exists {
+		return sheet
+	}
+	return ui.textureAtlas
 }
 
 func (ui *ui) stringToTexture(string string, color sdl.Color, size FontSize) *sdl.Texture {
@@ -151,111 +300,204 @@ func (ui *ui) stringToTexture(string string, color sdl.Color, size FontSize) *sd
 }
 
 func (ui *ui) QuitSDL() {
+	for _, spectator := range ui.spectators {
+		spectator.destroy()
+	}
+	ui.destroy()
 	sdl.Quit()
-	ui.window.Destroy()
+}
+
+// destroy closes just this window
+func (ui *ui) destroy() {
 	ui.renderer.Destroy()
+	ui.window.Destroy()
 	// ui.font.Close()
 }
 
-func (ui *ui) loadTextureIndex() {
-	ui.textureIndex = make(map[rune][]sdl.Rect)
-	file, err := os.Open("ui2d/assets/atlas-index.txt")
+// AddSpectator makes the spectator window part of this window's event loop
+func (ui *ui) AddSpectator(spectator *ui) {
+	ui.spectators = append(ui.spectators, spectator)
+}
+
+// cameraTarget is where the camera should point, the followed monster if it's still around
+func (ui *ui) cameraTarget(level *game.Level) game.Pos {
+	if ui.follow != "" {
+		for _, m := range level.Monsters {
+			if m.Name == ui.follow {
+				return m.Pos
+			}
+		}
+	}
+	return level.Player.Pos
+}
+
+// followNext switches a spectator to the next monster on the level, by name, and back to the player after the last one
+func (ui *ui) followNext() {
+	if ui.level == nil {
+		return
+	}
+	names := make([]string, 0, len(ui.level.Monsters))
+	for _, m := range ui.level.Monsters {
+		names = append(names, m.Name)
+	}
+	sort.Strings(names)
+
+	next := ""
+	for _, name := range names {
+		if name > ui.follow {
+			next = name
+			break
+		}
+	}
+	ui.follow = next
+	ui.centerX, ui.centerY = -1, -1
+	ui.Draw(ui.level)
+}
+
+// visible and seen are the tile's fog of war for the player, spectators see everything
+func (ui *ui) visible(tile game.Tile) bool {
+	return tile.Visible || ui.spectator
+}
+
+func (ui *ui) seen(tile game.Tile) bool {
+	return tile.Seen || tile.Visible || ui.spectator
+}
+
+// AtlasError is a line of a texture index file that couldn't be read
+type AtlasError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *AtlasError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+}
+
+func (e *AtlasError) Unwrap() error {
+	return e.Err
+}
+
+// loadTextureIndex reads an index file where each line is a rune followed by the x, y and
+// variation count of its sprites in a sheet of tileSize squares that is columns wide
+func loadTextureIndex(fsys fs.FS, path string, tileSize, columns int64) (map[rune][]sdl.Rect, error) {
+	textureIndex := make(map[rune][]sdl.Rect)
+	file, err := fsys.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
+	lineNum := 0
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 		tileRune := rune(line[0])
 
 		xy := line[1:]
 		splitXYC := strings.Split(xy, ",")
-		x, err := strconv.ParseInt(strings.TrimSpace(splitXYC[0]), 10, 24)
-		if err != nil {
-			panic(err)
+		if len(splitXYC) != 3 {
+			return nil, &AtlasError{path, lineNum, fmt.Errorf("expected a rune followed by x, y and a count, got %q", line)}
 		}
-
-		y, err := strconv.ParseInt(strings.TrimSpace(splitXYC[1]), 10, 64)
-		if err != nil {
-			panic(err)
-		}
-
-		variationCount, err := strconv.ParseInt(strings.TrimSpace(splitXYC[2]), 10, 64)
-		if err != nil {
-			panic(err)
+		var numbers [3]int64
+		for i, field := range splitXYC {
+			numbers[i], err = strconv.ParseInt(strings.TrimSpace(field), 10, 32)
+			if err != nil || numbers[i] < 0 {
+				return nil, &AtlasError{path, lineNum, fmt.Errorf("%q should be a whole number 0 or more", strings.TrimSpace(field))}
+			}
 		}
+		x, y, variationCount := numbers[0], numbers[1], numbers[2]
 
 		var rects []sdl.Rect
 		for i := 0; i < int(variationCount); i++ {
-			rects = append(rects, sdl.Rect{X: int32(x * 32), Y: int32(y * 32), W: 32, H: 32})
+			rects = append(rects, sdl.Rect{X: int32(x * tileSize), Y: int32(y * tileSize), W: int32(tileSize), H: int32(tileSize)})
 			x++
-			if x > 63 {
+			if x > columns-1 {
 				x = 0
 				y++
 			}
 		}
 
-		ui.textureIndex[tileRune] = rects
+		textureIndex[tileRune] = rects
 
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return textureIndex, nil
 }
 
 func (ui *ui) Draw(level *game.Level) {
+	ui.level = level
+	target := ui.cameraTarget(level)
 	if ui.centerX == -1 && ui.centerY == -1 {
-		ui.centerX = level.Player.X
-		ui.centerY = level.Player.Y
+		ui.centerX = target.X
+		ui.centerY = target.Y
 	}
 
 	threshold := 5
-	if level.Player.X > ui.centerX+threshold {
-		diff := level.Player.X - (ui.centerX + threshold)
+	if target.X > ui.centerX+threshold {
+		diff := target.X - (ui.centerX + threshold)
 		ui.centerX += diff
-	} else if level.Player.X < ui.centerX-threshold {
-		diff := (ui.centerX - threshold) - level.Player.X
+	} else if target.X < ui.centerX-threshold {
+		diff := (ui.centerX - threshold) - target.X
 		ui.centerX -= diff
-	} else if level.Player.Y > ui.centerY+threshold {
-		diff := level.Player.Y - (ui.centerY + threshold)
+	} else if target.Y > ui.centerY+threshold {
+		diff := target.Y - (ui.centerY + threshold)
 		ui.centerY += diff
-	} else if level.Player.Y < ui.centerY-threshold {
-		diff := (ui.centerY - threshold) - level.Player.Y
+	} else if target.Y < ui.centerY-threshold {
+		diff := (ui.centerY - threshold) - target.Y
 		ui.centerY -= diff
 	}
 
-	game.OffsetX = (ui.winWidth / 2) - (ui.centerX * 32)
-	game.OffsetY = (ui.winHeight / 2) - (ui.centerY * 32)
+	ui.offsetX = (ui.winWidth / 2) - (ui.centerX * 32)
+	ui.offsetY = (ui.winHeight / 2) - (ui.centerY * 32)
 	ui.renderer.Clear()
 	ui.r.Seed(63)
 
-	ui.drawFloor(level, game.OffsetX, game.OffsetY)
-	ui.drawLevel(level, game.OffsetX, game.OffsetY)
-	ui.drawOnFloor(level, game.OffsetX, game.OffsetY)
+	ui.drawFloor(level, ui.offsetX, ui.offsetY)
+	ui.drawLevel(level, ui.offsetX, ui.offsetY)
+	ui.drawOnFloor(level, ui.offsetX, ui.offsetY)
+	ui.drawItems(level, ui.offsetX, ui.offsetY)
+	ui.drawPath(level, ui.offsetX, ui.offsetY)
 
 	ui.textureAtlas.SetColorMod(255, 255, 255) // needed or sometimes entities stay modded
 
 	for pos, monster := range level.Monsters {
-		if level.Level[pos.Y][pos.X].Visible {
-			monsterSrcRect := ui.textureIndex[monster.Rune][0]
-
-			ui.renderer.Copy(ui.textureAtlas, &monsterSrcRect, &sdl.Rect{X: int32(pos.X*32 + game.OffsetX), Y: int32(pos.Y*32 + game.OffsetY), W: 32, H: 32})
+		srcs := ui.textureIndex[monster.Rune]
+		if ui.visible(level.Level[pos.Y][pos.X]) && len(srcs) > 0 {
+			monsterSrcRect := srcs[0]
+			sheet := ui.sheet(monster.Rune)
+			sheet.SetColorMod(255, 255, 255)
+			ui.renderer.Copy(sheet, &monsterSrcRect, &sdl.Rect{X: int32(pos.X*32 + ui.offsetX), Y: int32(pos.Y*32 + ui.offsetY), W: 32, H: 32})
 		}
 	}
 
 	// Player tile 13, 59
 	playerSrcRect := ui.textureIndex[game.PlayerTile][0]
-	ui.renderer.Copy(ui.textureAtlas, &playerSrcRect, &sdl.Rect{X: int32(level.Player.X*32 + game.OffsetX), Y: int32(level.Player.Y*32 + game.OffsetY), W: 32, H: 32}) //TODO: custom rect builder
+	playerSheet := ui.sheet(game.PlayerTile)
+	playerSheet.SetColorMod(255, 255, 255)
+	ui.renderer.Copy(playerSheet, &playerSrcRect, &sdl.Rect{X: int32(level.Player.X*32 + ui.offsetX), Y: int32(level.Player.Y*32 + ui.offsetY), W: 32, H: 32}) //TODO: custom rect builder
 
 	ui.drawUI(level)
+	if !level.Player.Alive {
+		ui.drawGameOver(level)
+	}
 
 	ui.renderer.Present()
 }
 
-func (ui *ui) renderDebug(level *game.Level, pos game.Pos) {
+func (ui *ui) renderDebug(sheet *sdl.Texture, level *game.Level, pos game.Pos) {
 	if level.Debug[pos] {
-		ui.textureAtlas.SetColorMod(128, 0, 0)
+		sheet.SetColorMod(128, 0, 0)
 	} else {
-		ui.textureAtlas.SetColorMod(255, 255, 255)
+		sheet.SetColorMod(255, 255, 255)
 	}
 }
 
@@ -266,23 +508,28 @@ func (ui *ui) drawLevel(level *game.Level, offsetX, offsetY int) {
 	for y, row := range level.Level {
 		// loop over each tile per row
 		for x, tile := range row {
-			if tile.Rune != game.Empty {
-				srcs := ui.textureIndex[tile.Rune]
+			srcs := ui.textureIndex[tile.Rune]
+			if tile.Rune != game.Empty && len(srcs) > 0 {
 				src := srcs[ui.r.Intn(len(srcs))]
-				if tile.Visible || tile.Seen {
-					if tile.Type == "Floor" || tile.Type == "Player" || tile.Type == "Monster" {
+				if ui.seen(tile) {
+					// pack floors have their own sprite over the dirt
+					if tile.Rune == game.DirtFloor || tile.Type == "Player" || tile.Type == "Monster" {
 						continue
 					}
 					dst := sdl.Rect{X: int32(x*32 + offsetX), Y: int32(y*32 + offsetY), W: 32, H: 32} // TODO: maybe add a util to build rects with a configurable spritesheet defaults eg x,y,w,h
 					pos := game.Pos{X: x, Y: y}
-					ui.renderDebug(level, pos)
-					if tile.Seen && !tile.Visible {
-						ui.textureAtlas.SetColorMod(128, 128, 128)
-					} else if tile.Visible {
-						ui.textureAtlas.SetColorMod(255, 255, 255)
+					sheet := ui.sheet(tile.Rune)
+					ui.renderDebug(sheet, level, pos)
+					if !ui.visible(tile) {
+						sheet.SetColorMod(128, 128, 128)
+					} else if tile.Rune == game.Torch {
+						// no torch sprite yet, it's a wall with a warm tint
+						sheet.SetColorMod(255, 180, 90)
+					} else {
+						sheet.SetColorMod(255, 255, 255)
 					}
 
-					ui.renderer.Copy(ui.textureAtlas, &src, &dst)
+					ui.renderer.Copy(sheet, &src, &dst)
 				}
 			}
 		}
@@ -295,13 +542,13 @@ func (ui *ui) drawFloor(level *game.Level, offsetX, offsetY int) {
 			if tile.HasFloor {
 				srcs := ui.textureIndex[game.DirtFloor]
 				src := srcs[ui.r.Intn(len(srcs))]
-				if tile.Visible || tile.Seen {
+				if ui.seen(tile) {
 					dst := sdl.Rect{X: int32(x*32 + offsetX), Y: int32(y*32 + offsetY), W: 32, H: 32}
 					pos := game.Pos{X: x, Y: y}
-					ui.renderDebug(level, pos)
-					if tile.Seen && !tile.Visible {
+					ui.renderDebug(ui.textureAtlas, level, pos)
+					if !ui.visible(tile) {
 						ui.textureAtlas.SetColorMod(128, 128, 128)
-					} else if tile.Visible {
+					} else {
 						ui.textureAtlas.SetColorMod(255, 255, 255)
 					}
 
@@ -319,11 +566,11 @@ func (ui *ui) drawOnFloor(level *game.Level, offsetX, offsetY int) {
 				srcs := ui.textureIndex[game.BloodStained]
 				src := srcs[ui.r.Intn(len(srcs))]
 				if tile.BloodStained {
-					if tile.Seen || tile.Visible {
+					if ui.seen(tile) {
 						dst := sdl.Rect{X: int32(x*32 + offsetX), Y: int32(y*32 + offsetY), W: 32, H: 32}
-						if tile.Seen && !tile.Visible {
+						if !ui.visible(tile) {
 							ui.textureAtlas.SetColorMod(128, 128, 128)
-						} else if tile.Visible {
+						} else {
 							ui.textureAtlas.SetColorMod(255, 255, 255)
 						}
 
@@ -335,33 +582,96 @@ func (ui *ui) drawOnFloor(level *game.Level, offsetX, offsetY int) {
 	}
 }
 
+func (ui *ui) drawItems(level *game.Level, offsetX, offsetY int) {
+	for pos, items := range level.Items {
+		tile := level.Level[pos.Y][pos.X]
+		if len(items) == 0 || !ui.seen(tile) {
+			continue
+		}
+
+		// only the top of the pile is drawn
+		srcs, exists := ui.itemIndex[items[len(items)-1].Rune]
+		if !exists {
+			continue
+		}
+		if ui.visible(tile) {
+			ui.itemAtlas.SetColorMod(255, 255, 255)
+		} else {
+			ui.itemAtlas.SetColorMod(128, 128, 128)
+		}
+		dst := sdl.Rect{X: int32(pos.X*32 + offsetX), Y: int32(pos.Y*32 + offsetY), W: 32, H: 32}
+		ui.renderer.Copy(ui.itemAtlas, &srcs[0], &dst)
+	}
+}
+
+// eventColour picks the colour of a log line by category, less important events are darker
+func eventColour(event game.Event) sdl.Color {
+	var c sdl.Color
+	switch event.Category {
+	case game.CombatEvent:
+		c = sdl.Color{255, 96, 96, 0}
+	case game.DeathEvent:
+		c = sdl.Color{220, 64, 220, 0}
+	case game.DoorEvent:
+		c = sdl.Color{210, 170, 90, 0}
+	case game.ItemEvent:
+		c = sdl.Color{120, 230, 120, 0}
+	default:
+		c = sdl.Color{140, 180, 255, 0}
+	}
+	if event.Severity == game.Info {
+		c.R, c.G, c.B = c.R/4*3, c.G/4*3, c.B/4*3
+	}
+	return c
+}
+
+// scrollLog moves the log panel back (positive) or forward through the event log
+func (ui *ui) scrollLog(lines int) {
+	ui.logScroll += lines
+	if ui.level != nil && ui.logScroll > len(ui.level.Events.Events)-1 {
+		ui.logScroll = len(ui.level.Events.Events) - 1
+	}
+	if ui.logScroll < 0 {
+		ui.logScroll = 0
+	}
+	if ui.level != nil {
+		ui.Draw(ui.level)
+	}
+}
+
 func (ui *ui) drawUI(level *game.Level) {
 	eventStart := int32(float64(ui.winHeight) * .72)
 	eventWidth := int32(float64(ui.winWidth) * .30)
 
 	ui.renderer.Copy(ui.panelBackground, nil, &sdl.Rect{0, eventStart, eventWidth, int32(ui.winHeight) - eventStart})
 
-	// loop and print events
-	i := level.EventPos
-	count := 0
+	// newest events at the bottom, the panel scrolls back through the whole log
 	_, fontSizeY, _ := ui.fontSmall.SizeUTF8("A")
-	for {
-		event := level.Events[i]
-
-		if event != "" {
-			tex := ui.stringToTexture(event, sdl.Color{255, 0, 0, 0}, FontSmall)
-			_, _, w, h, err := tex.Query()
-			if err != nil {
-				panic(err)
-			}
-			ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(count*fontSizeY) + eventStart, w, h})
+	lines := (ui.winHeight - int(eventStart)) / fontSizeY
+	var texts []string
+	var colours []sdl.Color
+	if ui.logScroll > 0 {
+		lines--
+	}
+	for _, event := range level.Events.Last(lines, ui.logScroll) {
+		text := event.String()
+		if event.Severity == game.Critical {
+			text = "!! " + text
 		}
-		i = (i + 1) % (len(level.Events))
-		count++
-
-		if i == level.EventPos {
-			break
+		texts = append(texts, text)
+		colours = append(colours, eventColour(event))
+	}
+	if ui.logScroll > 0 {
+		texts = append(texts, fmt.Sprintf("-- %d newer, Page Down --", ui.logScroll))
+		colours = append(colours, sdl.Color{255, 255, 255, 0})
+	}
+	for i, text := range texts {
+		tex := ui.stringToTexture(text, colours[i], FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
 		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{5, int32(i*fontSizeY) + eventStart, w, h})
 	}
 
 	statsStart := int32(float64(ui.winHeight) * .02)
@@ -381,6 +691,60 @@ func (ui *ui) drawUI(level *game.Level) {
 		}
 		ui.renderer.Copy(tex, nil, &sdl.Rect{10, int32(i*fontSizeY) + statsStart, w, h})
 	}
+
+	inventoryStart := statsWidth + int32(float64(ui.winWidth)*.01)
+	inventoryWidth := int32(float64(ui.winWidth) * .20)
+
+	ui.renderer.Copy(ui.panelBackground, nil, &sdl.Rect{inventoryStart, statsStart, inventoryWidth, statsHeight})
+
+	inventory := level.Player.GetInventoryStrings()
+
+	_, fontSizeY, _ = ui.fontSmall.SizeUTF8("A")
+	for i, line := range inventory {
+		tex := ui.stringToTexture(line, sdl.Color{255, 255, 255, 0}, FontSmall)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{inventoryStart + 10, int32(i*fontSizeY) + statsStart, w, h})
+	}
+}
+
+// drawPath marks the tiles of the travel path, or the path the player would take to the tile under the mouse
+func (ui *ui) drawPath(level *game.Level, offsetX, offsetY int) {
+	for _, pos := range level.Path {
+		ui.renderer.Copy(ui.pathMarker, nil, &sdl.Rect{X: int32(pos.X*32 + offsetX + 8), Y: int32(pos.Y*32 + offsetY + 8), W: 16, H: 16})
+	}
+}
+
+// drawGameOver darkens the whole screen and says how the player died
+func (ui *ui) drawGameOver(level *game.Level) {
+	ui.renderer.Copy(ui.panelBackground, nil, nil)
+	ui.renderer.Copy(ui.panelBackground, nil, nil)
+
+	lines := []struct {
+		text string
+		size FontSize
+	}{
+		{"You died", FontLarge},
+		{level.Player.CauseOfDeath, FontMedium},
+		{fmt.Sprintf("Survived %d turns", level.Player.DeathTurn), FontMedium},
+		{"Press R to restart or F9 to load", FontSmall},
+	}
+
+	y := int32(float64(ui.winHeight) * .35)
+	for _, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		tex := ui.stringToTexture(line.text, sdl.Color{255, 255, 255, 0}, line.size)
+		_, _, w, h, err := tex.Query()
+		if err != nil {
+			panic(err)
+		}
+		ui.renderer.Copy(tex, nil, &sdl.Rect{int32(ui.winWidth/2) - w/2, y, w, h})
+		y += h + 10
+	}
 }
 
 func (ui *ui) GetSinglePixelTex(colour sdl.Color) *sdl.Texture {
@@ -398,70 +762,208 @@ func (ui *ui) GetSinglePixelTex(colour sdl.Color) *sdl.Texture {
 	return tex
 }
 
+// GetInput runs the event loop for this window and its spectators. SDL has a single event queue so
+// every window has to share one loop, events are handed to the window they happened in
 func (ui *ui) GetInput() {
 	for {
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-			var input game.Input
 			switch e := event.(type) {
 			case *sdl.QuitEvent:
-				input.Type = game.CloseWindow
-				input.LevelChan = ui.levelChan
-				ui.QuitSDL()
+				ui.closeWindows()
 				return
 			case *sdl.WindowEvent:
 				if e.Event == sdl.WINDOWEVENT_CLOSE {
-					input.Type = game.CloseWindow
-					input.LevelChan = ui.levelChan
-					ui.QuitSDL()
-					return
+					if e.WindowID == ui.windowID {
+						ui.closeWindows()
+						return
+					}
+					ui.closeSpectator(e.WindowID)
 				}
 			case *sdl.MouseButtonEvent:
-				if e.State == sdl.PRESSED {
-					if e.Button == sdl.BUTTON_LEFT {
-						mousePos := game.Pos{X: int(e.X), Y: int(e.Y)}
-						ui.inputChan <- &game.Input{
-							Type:     game.Search,
-							MousePos: mousePos,
-						}
-					}
-					if e.Button == sdl.BUTTON_RIGHT {
-						mousePos := game.Pos{X: int(e.X), Y: int(e.Y)}
-						ui.inputChan <- &game.Input{
-							Type:     game.Inspect,
-							MousePos: mousePos,
-						}
-					}
+				if e.WindowID == ui.windowID && !ui.spectator {
+					ui.handleMouse(e)
+				}
+			case *sdl.MouseMotionEvent:
+				if e.WindowID == ui.windowID && !ui.spectator {
+					ui.handleHover(e)
+				}
+			case *sdl.MouseWheelEvent:
+				if e.WindowID == ui.windowID {
+					ui.scrollLog(int(e.Y) * 3)
 				}
 			case *sdl.KeyboardEvent:
 				if e.Type != sdl.KEYDOWN {
 					break
 				}
-				var key sdl.Keycode
-				switch key = e.Keysym.Sym; key {
-				case sdl.K_ESCAPE:
-					input.Type = game.CloseWindow
-					input.LevelChan = ui.levelChan
-					ui.QuitSDL()
+				if e.WindowID != ui.windowID {
+					ui.handleSpectatorKey(e)
+					break
+				}
+				if e.Keysym.Sym == sdl.K_ESCAPE {
+					ui.closeWindows()
 					return
-				case sdl.K_UP, sdl.K_w:
-					ui.inputChan <- &game.Input{Type: game.Up}
-				case sdl.K_DOWN, sdl.K_s:
-					ui.inputChan <- &game.Input{Type: game.Down}
-				case sdl.K_LEFT, sdl.K_a:
-					ui.inputChan <- &game.Input{Type: game.Left}
-				case sdl.K_RIGHT, sdl.K_d:
-					ui.inputChan <- &game.Input{Type: game.Right}
 				}
-			}
-
-			select {
-			case newLevel, ok := <-ui.levelChan:
-				if ok {
-					ui.Draw(newLevel)
+				if ui.spectator {
+					ui.spectatorKey(e)
+				} else {
+					ui.handleKey(e)
 				}
-			default:
 			}
-			sdl.Delay(16)
 		}
+
+		ui.drawLatest()
+		for _, spectator := range ui.spectators {
+			spectator.drawLatest()
+		}
+		sdl.Delay(16)
+	}
+}
+
+// drawLatest draws the level if the game has sent a new one
+func (ui *ui) drawLatest() {
+	select {
+	case newLevel, ok := <-ui.levelChan:
+		if ok {
+			ui.Draw(newLevel)
+		}
+	default:
+	}
+}
+
+// closeWindows tells the game every window is going away and shuts SDL down
+func (ui *ui) closeWindows() {
+	for _, spectator := range ui.spectators {
+		ui.sendClose(spectator.levelChan)
+	}
+	ui.sendClose(ui.levelChan)
+	ui.QuitSDL()
+}
+
+// sendClose lets the game know a window closed. The game might have stopped already, a finished
+// replay for example, so it doesn't wait forever
+func (ui *ui) sendClose(levelChan chan *game.Level) {
+	select {
+	case ui.inputChan <- &game.Input{Type: game.CloseWindow, LevelChan: levelChan}:
+	case <-time.After(time.Second):
+	}
+}
+
+func (ui *ui) closeSpectator(windowID uint32) {
+	for i, spectator := range ui.spectators {
+		if spectator.windowID == windowID {
+			ui.sendClose(spectator.levelChan)
+			spectator.destroy()
+			ui.spectators = append(ui.spectators[:i], ui.spectators[i+1:]...)
+			return
+		}
+	}
+}
+
+// handleSpectatorKey passes a key pressed in one of the spectator windows on to it
+func (ui *ui) handleSpectatorKey(e *sdl.KeyboardEvent) {
+	for _, spectator := range ui.spectators {
+		if spectator.windowID != e.WindowID {
+			continue
+		}
+		if e.Keysym.Sym == sdl.K_ESCAPE {
+			ui.closeSpectator(e.WindowID)
+		} else {
+			spectator.spectatorKey(e)
+		}
+		return
+	}
+}
+
+// spectatorKey lets spectators pick who to follow, tab goes through the monsters and p goes back to the player
+func (ui *ui) spectatorKey(e *sdl.KeyboardEvent) {
+	switch e.Keysym.Sym {
+	case sdl.K_TAB:
+		ui.followNext()
+	case sdl.K_p:
+		ui.follow = ""
+		ui.centerX, ui.centerY = -1, -1
+		if ui.level != nil {
+			ui.Draw(ui.level)
+		}
+	}
+}
+
+func (ui *ui) handleMouse(e *sdl.MouseButtonEvent) {
+	if e.State != sdl.PRESSED {
+		return
+	}
+	mapPos := ui.mapPos(e.X, e.Y)
+	if e.Button == sdl.BUTTON_LEFT {
+		ui.inputChan <- &game.Input{
+			Type:   game.Travel,
+			MapPos: &mapPos,
+		}
+	}
+	if e.Button == sdl.BUTTON_RIGHT {
+		ui.inputChan <- &game.Input{
+			Type:   game.Inspect,
+			MapPos: &mapPos,
+		}
+	}
+}
+
+// mapPos is the map position under x, y in the window going by where the camera was last drawn
+func (ui *ui) mapPos(x, y int32) game.Pos {
+	return game.Pos{X: (int(x) - ui.offsetX) / 32, Y: (int(y) - ui.offsetY) / 32}
+}
+
+// handleHover asks the game for a path preview when the mouse moves onto a different tile
+func (ui *ui) handleHover(e *sdl.MouseMotionEvent) {
+	tile := ui.mapPos(e.X, e.Y)
+	if tile == ui.hoverTile {
+		return
+	}
+	ui.hoverTile = tile
+	ui.inputChan <- &game.Input{
+		Type:   game.Hover,
+		MapPos: &tile,
+	}
+}
+
+func (ui *ui) handleKey(e *sdl.KeyboardEvent) {
+	var key sdl.Keycode
+	switch key = e.Keysym.Sym; key {
+	case sdl.K_UP, sdl.K_w, sdl.K_KP_8, sdl.K_k:
+		ui.inputChan <- &game.Input{Type: game.Up}
+	case sdl.K_DOWN, sdl.K_s, sdl.K_KP_2, sdl.K_j:
+		ui.inputChan <- &game.Input{Type: game.Down}
+	case sdl.K_LEFT, sdl.K_a, sdl.K_KP_4, sdl.K_h:
+		ui.inputChan <- &game.Input{Type: game.Left}
+	case sdl.K_RIGHT, sdl.K_d, sdl.K_KP_6, sdl.K_l:
+		ui.inputChan <- &game.Input{Type: game.Right}
+	case sdl.K_KP_7, sdl.K_y:
+		ui.inputChan <- &game.Input{Type: game.UpLeft}
+	case sdl.K_KP_9, sdl.K_u:
+		ui.inputChan <- &game.Input{Type: game.UpRight}
+	case sdl.K_KP_1, sdl.K_b:
+		ui.inputChan <- &game.Input{Type: game.DownLeft}
+	case sdl.K_KP_3, sdl.K_n:
+		ui.inputChan <- &game.Input{Type: game.DownRight}
+	case sdl.K_r:
+		ui.inputChan <- &game.Input{Type: game.Restart}
+	case sdl.K_g:
+		ui.inputChan <- &game.Input{Type: game.PickUp}
+	case sdl.K_o:
+		ui.inputChan <- &game.Input{Type: game.Explore}
+	case sdl.K_1, sdl.K_2, sdl.K_3, sdl.K_4, sdl.K_5, sdl.K_6, sdl.K_7, sdl.K_8, sdl.K_9:
+		// number keys use an inventory slot, holding shift drops it instead
+		itemInput := &game.Input{Type: game.UseItem, ItemIndex: int(key - sdl.K_1)}
+		if e.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+			itemInput.Type = game.Drop
+		}
+		ui.inputChan <- itemInput
+	case sdl.K_PAGEUP:
+		ui.scrollLog(5)
+	case sdl.K_PAGEDOWN:
+		ui.scrollLog(-5)
+	case sdl.K_F5:
+		ui.inputChan <- &game.Input{Type: game.QuickSave}
+	case sdl.K_F9:
+		ui.inputChan <- &game.Input{Type: game.QuickLoad}
 	}
 }
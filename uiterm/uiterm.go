@@ -0,0 +1,437 @@
+package uiterm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"rpg-sdl/game"
+	"strconv"
+	"strings"
+)
+
+// ANSI colours used for the different things on the map
+const (
+	reset     = "\x1b[0m"
+	bold      = "\x1b[1m"
+	dim       = "\x1b[90m"
+	red       = "\x1b[31m"
+	green     = "\x1b[32m"
+	yellow    = "\x1b[33m"
+	blue      = "\x1b[34m"
+	magenta   = "\x1b[35m"
+	cyan      = "\x1b[36m"
+	white     = "\x1b[37m"
+	clear     = "\x1b[2J"
+	home      = "\x1b[H"
+	clearLine = "\x1b[K"
+	hideCur   = "\x1b[?25l"
+	showCur   = "\x1b[?25h"
+)
+
+const (
+	statsWidth  = 24
+	eventHeight = 10
+)
+
+type ui struct {
+	tty       *os.File
+	out       *os.File
+	termState string
+	termW     int
+	termH     int
+	centerX   int
+	centerY   int
+	levelChan chan *game.Level
+	inputChan chan *game.Input
+	dropping  bool
+	stop      chan struct{} // closed to make drawLoop return
+	drawn     chan struct{} // closed by drawLoop when it has returned
+}
+
+// NewUI sets up a terminal front end that talks to the game over the same channels as ui2d
+// it uses the controlling terminal when there is one and falls back to stdin and stdout
+func NewUI(inputChan chan *game.Input, levelChan chan *game.Level) (*ui, error) {
+	ui := &ui{}
+	ui.inputChan = inputChan
+	ui.levelChan = levelChan
+	ui.centerX = -1
+	ui.centerY = -1
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		ui.tty = os.Stdin
+		ui.out = os.Stdout
+	} else {
+		ui.tty = tty
+		ui.out = tty
+	}
+
+	ui.termW, ui.termH = 80, 24
+	size, err := ui.stty("size")
+	if err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			h, errH := strconv.Atoi(fields[0])
+			w, errW := strconv.Atoi(fields[1])
+			if errH == nil && errW == nil && w > 0 && h > 0 {
+				ui.termW, ui.termH = w, h
+			}
+		}
+	}
+
+	ui.termState, err = ui.stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("not a terminal: %w", err)
+	}
+	_, err = ui.stty("raw", "-echo")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't switch the terminal to raw mode: %w", err)
+	}
+
+	fmt.Fprint(ui.out, clear+hideCur)
+
+	return ui, nil
+}
+
+// stty runs stty against our terminal, there is no raw mode in the standard library
+func (ui *ui) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = ui.tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (ui *ui) QuitTerm() {
+	fmt.Fprint(ui.out, reset+clear+home+showCur)
+	ui.stty(ui.termState)
+	if ui.tty != os.Stdin {
+		ui.tty.Close()
+	}
+}
+
+func (ui *ui) Draw(level *game.Level) {
+	mapW := ui.termW - statsWidth - 1
+	mapH := ui.termH - eventHeight - 1
+	if mapW < 1 {
+		mapW = 1
+	}
+	if mapH < 1 {
+		mapH = 1
+	}
+
+	if ui.centerX == -1 && ui.centerY == -1 {
+		ui.centerX = level.Player.X
+		ui.centerY = level.Player.Y
+	}
+
+	// same lazy camera as ui2d, only move when the player gets near the edge
+	thresholdX := mapW / 4
+	thresholdY := mapH / 4
+	if level.Player.X > ui.centerX+thresholdX {
+		ui.centerX = level.Player.X - thresholdX
+	} else if level.Player.X < ui.centerX-thresholdX {
+		ui.centerX = level.Player.X + thresholdX
+	}
+	if level.Player.Y > ui.centerY+thresholdY {
+		ui.centerY = level.Player.Y - thresholdY
+	} else if level.Player.Y < ui.centerY-thresholdY {
+		ui.centerY = level.Player.Y + thresholdY
+	}
+
+	startX := ui.centerX - mapW/2
+	startY := ui.centerY - mapH/2
+
+	stats := level.Player.GetStatStrings()
+	stats = append(stats, "")
+	stats = append(stats, level.Player.GetInventoryStrings()...)
+
+	var sb strings.Builder
+	sb.WriteString(home)
+	for row := 0; row < mapH; row++ {
+		y := startY + row
+		for col := 0; col < mapW; col++ {
+			x := startX + col
+			sb.WriteString(ui.cell(level, game.Pos{X: x, Y: y}))
+		}
+		sb.WriteString(reset + " ")
+		if row < len(stats) {
+			sb.WriteString(bold + stats[row] + reset)
+		}
+		sb.WriteString(clearLine + "\r\n")
+	}
+
+	ui.drawEvents(level, &sb)
+	if !level.Player.Alive {
+		ui.drawGameOver(level, &sb, mapH)
+	}
+
+	fmt.Fprint(ui.out, sb.String())
+}
+
+// cell returns the coloured character for a single map position
+func (ui *ui) cell(level *game.Level, pos game.Pos) string {
+	if pos.Y < 0 || pos.Y >= len(level.Level) || pos.X < 0 || pos.X >= len(level.Level[pos.Y]) {
+		return " "
+	}
+
+	tile := level.Level[pos.Y][pos.X]
+	if !tile.Visible && !tile.Seen {
+		return " "
+	}
+
+	if tile.Visible {
+		if pos == level.Player.Pos {
+			return bold + yellow + string(game.PlayerTile) + reset
+		}
+		if m, exists := level.Monsters[pos]; exists {
+			return red + string(m.Rune) + reset
+		}
+	}
+
+	if items := level.Items[pos]; len(items) > 0 {
+		if tile.Visible {
+			return green + string(items[len(items)-1].Rune) + reset
+		}
+		return dim + string(items[len(items)-1].Rune) + reset
+	}
+
+	r := tile.Rune
+	colour := white
+	switch r {
+	case game.Empty:
+		return " "
+	case game.Water:
+		colour = blue
+	case game.ClosedDoor, game.OpenDoor, game.Torch:
+		colour = yellow
+	case game.UpStairs, game.DownStairs:
+		colour = magenta
+	}
+	if tile.BloodStained {
+		colour = red
+	}
+	if !tile.Visible {
+		colour = dim
+	}
+
+	return colour + string(r) + reset
+}
+
+func (ui *ui) drawEvents(level *game.Level, sb *strings.Builder) {
+	sb.WriteString(cyan + strings.Repeat("-", ui.termW-1) + reset + clearLine + "\r\n")
+
+	lines := 0
+	for _, event := range level.Events.Last(eventHeight-1, 0) {
+		colour := cyan
+		switch event.Category {
+		case game.CombatEvent:
+			colour = red
+		case game.DeathEvent:
+			colour = magenta
+		case game.DoorEvent:
+			colour = yellow
+		case game.ItemEvent:
+			colour = green
+		}
+		if event.Severity == game.Critical {
+			colour += bold
+		}
+		sb.WriteString(colour + event.String() + reset + clearLine + "\r\n")
+		lines++
+	}
+	for ; lines < eventHeight-1; lines++ {
+		sb.WriteString(clearLine + "\r\n")
+	}
+}
+
+// drawGameOver writes a box over the middle of the map saying how the player died
+func (ui *ui) drawGameOver(level *game.Level, sb *strings.Builder, mapH int) {
+	lines := []string{
+		"You died",
+		level.Player.CauseOfDeath,
+		fmt.Sprintf("Survived %d turns", level.Player.DeathTurn),
+		"",
+		"r to restart, F9 to load, q to quit",
+	}
+
+	width := 0
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	width += 4
+
+	top := mapH/2 - len(lines)/2
+	left := (ui.termW-statsWidth)/2 - width/2
+	if top < 1 {
+		top = 1
+	}
+	if left < 1 {
+		left = 1
+	}
+
+	for i, line := range append(append([]string{""}, lines...), "") {
+		// cursor positions in escape codes start at 1
+		fmt.Fprintf(sb, "\x1b[%d;%dH", top+i, left)
+		padding := width - len(line)
+		sb.WriteString(bold + red + "\x1b[47m" + strings.Repeat(" ", padding/2) + line + strings.Repeat(" ", padding-padding/2) + reset)
+	}
+}
+
+// escapeKey works out the key behind an escape sequence at the start of keys and how many bytes it
+// takes up. Keys we don't use, Alt+key and a lone ESC come back as game.None so they get skipped
+func escapeKey(keys []byte) (game.InputType, int) {
+	if len(keys) < 2 {
+		return game.None, 1
+	}
+	switch keys[1] {
+	case '[':
+		// ESC [ then any number of parameter bytes and a final byte, like ESC [ A or ESC [ 1 5 ~
+		end := 2
+		for end < len(keys) && (keys[end] < 0x40 || keys[end] > 0x7e) {
+			end++
+		}
+		if end == len(keys) {
+			return game.None, len(keys)
+		}
+		params := string(keys[2:end])
+		if keys[end] == '~' {
+			switch params {
+			case "15":
+				return game.QuickSave, end + 1
+			case "20":
+				return game.QuickLoad, end + 1
+			// the corners of the numpad with num lock off
+			case "1":
+				return game.UpLeft, end + 1
+			case "4":
+				return game.DownLeft, end + 1
+			case "5":
+				return game.UpRight, end + 1
+			case "6":
+				return game.DownRight, end + 1
+			}
+			return game.None, end + 1
+		}
+		return cursorKey(keys[end]), end + 1
+	case 'O':
+		// some terminals send the cursor keys as ESC O A
+		if len(keys) < 3 {
+			return game.None, len(keys)
+		}
+		return cursorKey(keys[2]), 3
+	}
+	// Alt+key
+	return game.None, 2
+}
+
+func cursorKey(final byte) game.InputType {
+	switch final {
+	case 'A':
+		return game.Up
+	case 'B':
+		return game.Down
+	case 'C':
+		return game.Right
+	case 'D':
+		return game.Left
+	case 'H':
+		return game.UpLeft
+	case 'F':
+		return game.DownLeft
+	}
+	return game.None
+}
+
+// drawLoop draws whatever the game broadcasts until stop is closed. Those are snapshots so drawing
+// them alongside GetInput is fine, the game never changes them
+func (ui *ui) drawLoop() {
+	defer close(ui.drawn)
+	for {
+		select {
+		case level, ok := <-ui.levelChan:
+			if !ok {
+				return
+			}
+			ui.Draw(level)
+		case <-ui.stop:
+			return
+		}
+	}
+}
+
+// GetInput reads keys from the terminal in raw mode until the player quits
+func (ui *ui) GetInput() {
+	ui.stop = make(chan struct{})
+	ui.drawn = make(chan struct{})
+	go ui.drawLoop()
+	defer func() {
+		// drawLoop writes to the terminal too, it has to be done before it's put back
+		close(ui.stop)
+		<-ui.drawn
+		ui.QuitTerm()
+	}()
+
+	buf := make([]byte, 32)
+	for {
+		n, err := ui.tty.Read(buf)
+		if err != nil || n == 0 {
+			ui.inputChan <- &game.Input{Type: game.QuitGame}
+			return
+		}
+
+		// a single read can hold several keys when they are typed quickly
+		keys := buf[:n]
+		for len(keys) > 0 {
+			var input game.Input
+			size := 1
+			switch {
+			case keys[0] == 27:
+				input.Type, size = escapeKey(keys)
+			case keys[0] == 'q', keys[0] == 3: // ctrl+c doesn't send a signal in raw mode
+				ui.inputChan <- &game.Input{Type: game.QuitGame}
+				return
+			case keys[0] >= '1' && keys[0] <= '9':
+				input.Type = game.UseItem
+				if ui.dropping {
+					input.Type = game.Drop
+				}
+				input.ItemIndex = int(keys[0] - '1')
+			case keys[0] == 'x':
+				// x followed by a number drops that slot
+				ui.dropping = true
+				keys = keys[size:]
+				continue
+			case keys[0] == 'r':
+				input.Type = game.Restart
+			case keys[0] == 'g':
+				input.Type = game.PickUp
+			case keys[0] == 'o':
+				input.Type = game.Explore
+			case keys[0] == 'w', keys[0] == 'k':
+				input.Type = game.Up
+			case keys[0] == 's', keys[0] == 'j':
+				input.Type = game.Down
+			case keys[0] == 'a', keys[0] == 'h':
+				input.Type = game.Left
+			case keys[0] == 'd', keys[0] == 'l':
+				input.Type = game.Right
+			case keys[0] == 'y':
+				input.Type = game.UpLeft
+			case keys[0] == 'u':
+				input.Type = game.UpRight
+			case keys[0] == 'b':
+				input.Type = game.DownLeft
+			case keys[0] == 'n':
+				input.Type = game.DownRight
+			}
+			keys = keys[size:]
+			ui.dropping = false
+
+			if input.Type != game.None {
+				ui.inputChan <- &input
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package uiterm
+
+import (
+	"rpg-sdl/game"
+	"testing"
+	"time"
+)
+
+func TestEscapeKey(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want game.InputType
+		size int
+	}{
+		{"lone escape", "\x1b", game.None, 1},
+		{"up", "\x1b[A", game.Up, 3},
+		{"up followed by a key", "\x1b[Aw", game.Up, 3},
+		{"application mode up", "\x1bOA", game.Up, 3},
+		{"application mode left", "\x1bOD", game.Left, 3},
+		{"home", "\x1b[H", game.UpLeft, 3},
+		{"ctrl+right", "\x1b[1;5C", game.Right, 6},
+		{"f5", "\x1b[15~", game.QuickSave, 5},
+		{"f9", "\x1b[20~", game.QuickLoad, 5},
+		{"numpad page down", "\x1b[6~", game.DownRight, 4},
+		{"unused function key", "\x1b[17~", game.None, 5},
+		{"unused final byte", "\x1b[Z", game.None, 3},
+		{"alt+q", "\x1bq", game.None, 2},
+		{"cut off sequence", "\x1b[1", game.None, 3},
+	}
+	for _, tt := range tests {
+		got, size := escapeKey([]byte(tt.keys))
+		if got != tt.want || size != tt.size {
+			t.Errorf("%s: escapeKey(%q) = %v, %d, want %v, %d", tt.name, tt.keys, got, size, tt.want, tt.size)
+		}
+	}
+}
+
+func TestDrawLoopStops(t *testing.T) {
+	ui := &ui{levelChan: make(chan *game.Level), stop: make(chan struct{}), drawn: make(chan struct{})}
+	go ui.drawLoop()
+	close(ui.stop)
+	select {
+	case <-ui.drawn:
+	case <-time.After(time.Second):
+		t.Fatal("drawLoop kept going after stop was closed")
+	}
+}